@@ -39,7 +39,9 @@ func (delegate *RemoteEchoClientDelegate) Initialize(ctx application.Context) {
 
 	r, p := echo.CreateMessagePipeForRemoteEcho()
 
-	v23.ConnectToRemoteService(ctx, &r, remoteEndpoint)
+	if err := v23.Connect(ctx, &r, remoteEndpoint); err != nil {
+		log.Fatalf("connecting to %s: %v", remoteEndpoint, err)
+	}
 	echoProxy := echo.NewRemoteEchoProxy(p, bindings.GetAsyncWaiter())
 
 	log.Printf("RemoteEchoClientDelegate.Initialize calling EchoString...")
@@ -36,7 +36,9 @@ func (delegate *FortuneClientDelegate) Initialize(ctx application.Context) {
 
 	log.Printf("FortuneClientDelegate.Initialize... %s", remoteEndpoint)
 	fortuneRequest, fortunePointer := fortune.CreateMessagePipeForFortune()
-	v23.ConnectToRemoteService(ctx, &fortuneRequest, remoteEndpoint)
+	if err := v23.Connect(ctx, &fortuneRequest, remoteEndpoint); err != nil {
+		log.Fatalf("connecting to %s: %v", remoteEndpoint, err)
+	}
 	fortuneProxy := fortune.NewFortuneProxy(fortunePointer, bindings.GetAsyncWaiter())
 
 	if addFortune != "" {
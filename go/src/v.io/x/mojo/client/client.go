@@ -5,27 +5,64 @@
 package client
 
 import (
+	"fmt"
+
 	"mojo/public/go/application"
 	"mojo/public/go/bindings"
 
 	"mojom/v23clientproxy"
 )
 
+// Connect connects r to the mojo service at v23Name, usually the address of
+// an export of a v23serverproxy. If the client proxy rejects the request,
+// r's message pipe is closed and the error is returned.
+func Connect(ctx application.Context, r application.ServiceRequest, v23Name string, opts ...Option) error {
+	c := newConfig(opts)
+	prox := connectToClientProxy(ctx, c.proxyURL)
+	defer prox.Close_Proxy()
+	return setupClientProxy(prox, r, v23Name, c)
+}
+
+// ConnectToRemoteService connects r to the mojo service at v23Name, usually
+// the address of an export of a v23serverproxy. It panics if the connection
+// cannot be set up.
+//
+// Deprecated: use Connect, which returns an error instead.
 func ConnectToRemoteService(ctx application.Context, r application.ServiceRequest, v23Name string) {
+	if err := Connect(ctx, r, v23Name); err != nil {
+		panic(err)
+	}
+}
+
+// connectToClientProxy returns a proxy for the V23ClientProxy interface of
+// the v23clientproxy app at url.
+func connectToClientProxy(ctx application.Context, url string) *v23clientproxy.V23ClientProxy_Proxy {
 	v23r, v23p := v23clientproxy.CreateMessagePipeForV23ClientProxy()
-	ctx.ConnectToApplication("https://mojo.v.io/v23clientproxy.mojo").ConnectToService(&v23r)
-	prox := v23clientproxy.NewV23ClientProxyProxy(v23p, bindings.GetAsyncWaiter())
+	ctx.ConnectToApplication(url).ConnectToService(&v23r)
+	return v23clientproxy.NewV23ClientProxyProxy(v23p, bindings.GetAsyncWaiter())
+}
+
+// setupClientProxy asks the client proxy to forward the messages sent over r
+// to v23Name.
+func setupClientProxy(prox *v23clientproxy.V23ClientProxy_Proxy, r application.ServiceRequest, v23Name string, c config) error {
 	sd := r.ServiceDescription()
 	mojomInterfaceType, err := sd.GetTopLevelInterface()
 	if err != nil {
-		// The service description must have the MojomInterface type.
-		panic(err)
+		r.PassMessagePipe().Close()
+		return fmt.Errorf("%s has no interface description: %v", r.Name(), err)
 	}
 	desc, err := sd.GetAllTypeDefinitions()
 	if err != nil {
-		// The service description must have the map of UserDefinedTypes.
-		panic(err)
+		r.PassMessagePipe().Close()
+		return fmt.Errorf("%s has no type definitions: %v", r.Name(), err)
 	}
 
-	prox.SetupClientProxy(v23Name, mojomInterfaceType, *desc, r.Name(), r.PassMessagePipe())
+	outError, err := prox.SetupClientProxyWithOptions(v23Name, mojomInterfaceType, *desc, r.Name(), r.PassMessagePipe(), c.proxyOptions())
+	if err != nil {
+		return err
+	}
+	if outError != nil {
+		return fmt.Errorf("connecting %s to %s: %s", r.Name(), v23Name, *outError)
+	}
+	return nil
 }
@@ -0,0 +1,72 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"mojo/public/go/application"
+
+	"mojom/v23clientproxy"
+)
+
+// A Chooser picks one of the instances found for an interface. It returns
+// false if none of them is acceptable.
+type Chooser func(instances []v23clientproxy.ServiceInstance) (v23clientproxy.ServiceInstance, bool)
+
+// FirstFound chooses the instance that was found first.
+func FirstFound(instances []v23clientproxy.ServiceInstance) (v23clientproxy.ServiceInstance, bool) {
+	if len(instances) == 0 {
+		return v23clientproxy.ServiceInstance{}, false
+	}
+	return instances[0], true
+}
+
+// WithAttributes returns a Chooser that picks the first instance whose
+// attributes include every key and value in attrs.
+func WithAttributes(attrs map[string]string) Chooser {
+	return func(instances []v23clientproxy.ServiceInstance) (v23clientproxy.ServiceInstance, bool) {
+	nextInstance:
+		for _, instance := range instances {
+			for k, v := range attrs {
+				if got, ok := instance.Attributes[k]; !ok || got != v {
+					continue nextInstance
+				}
+			}
+			return instance, true
+		}
+		return v23clientproxy.ServiceInstance{}, false
+	}
+}
+
+// ConnectToDiscoveredService connects r to an instance of the mojo service
+// r.Name() that is advertised through Vanadium discovery, e.g., by a
+// v23serverproxy that exports it. The client proxy scans for up to timeout
+// and choose picks one of the instances found. opts configure the connection
+// as for Connect. If no instance is connected to, r is closed.
+func ConnectToDiscoveredService(ctx application.Context, r application.ServiceRequest, timeout time.Duration, choose Chooser, opts ...Option) error {
+	c := newConfig(opts)
+	prox := connectToClientProxy(ctx, c.proxyURL)
+	defer prox.Close_Proxy()
+	instances, outError, err := prox.FindServices(r.Name(), uint32(timeout/time.Millisecond))
+	if err == nil && outError != nil {
+		err = fmt.Errorf("finding %s: %s", r.Name(), *outError)
+	}
+	if err != nil {
+		r.PassMessagePipe().Close()
+		return err
+	}
+	instance, ok := choose(instances)
+	if !ok {
+		r.PassMessagePipe().Close()
+		return fmt.Errorf("found %d instances of %s, but none was chosen", len(instances), r.Name())
+	}
+	if len(instance.V23Names) == 0 {
+		r.PassMessagePipe().Close()
+		return fmt.Errorf("instance %q of %s has no v23 names", instance.InstanceName, r.Name())
+	}
+	return setupClientProxy(prox, r, instance.V23Names[0], c)
+}
@@ -0,0 +1,39 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client_test
+
+import (
+	"testing"
+
+	"mojom/v23clientproxy"
+
+	"v.io/x/mojo/client"
+)
+
+func TestChoosers(t *testing.T) {
+	instances := []v23clientproxy.ServiceInstance{
+		{InstanceName: "a", Attributes: map[string]string{"mojo_url": "https://mojo.v.io/echo_server.mojo"}},
+		{InstanceName: "b", Attributes: map[string]string{"mojo_url": "https://mojo.v.io/dart-examples/echo_server/lib/main.dart"}},
+	}
+	tests := []struct {
+		choose client.Chooser
+		want   string
+		ok     bool
+	}{
+		{client.FirstFound, "a", true},
+		{client.WithAttributes(nil), "a", true},
+		{client.WithAttributes(map[string]string{"mojo_url": "https://mojo.v.io/dart-examples/echo_server/lib/main.dart"}), "b", true},
+		{client.WithAttributes(map[string]string{"mojo_url": "mojo:other"}), "", false},
+	}
+	for i, test := range tests {
+		got, ok := test.choose(instances)
+		if ok != test.ok || got.InstanceName != test.want {
+			t.Errorf("test %d: got (%q, %v), want (%q, %v)", i, got.InstanceName, ok, test.want, test.ok)
+		}
+	}
+	if _, ok := client.FirstFound(nil); ok {
+		t.Errorf("FirstFound chose an instance out of none")
+	}
+}
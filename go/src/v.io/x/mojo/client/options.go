@@ -0,0 +1,75 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"time"
+
+	"mojom/v23clientproxy"
+)
+
+// DefaultProxyURL is the url of the v23clientproxy app used unless the
+// ProxyURL option says otherwise.
+const DefaultProxyURL = "https://mojo.v.io/v23clientproxy.mojo"
+
+// An Option configures how Connect and ConnectToDiscoveredService set up a
+// connection.
+type Option func(*config)
+
+type config struct {
+	proxyURL        string
+	serverBlessings []string
+	callTimeout     time.Duration
+	attempts        int
+	backoff         time.Duration
+}
+
+func newConfig(opts []Option) config {
+	c := config{proxyURL: DefaultProxyURL}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+func (c config) proxyOptions() v23clientproxy.ClientProxyOptions {
+	o := v23clientproxy.ClientProxyOptions{
+		CallTimeoutMs:  uint32(c.callTimeout / time.Millisecond),
+		MaxAttempts:    uint32(c.attempts),
+		RetryBackoffMs: uint32(c.backoff / time.Millisecond),
+	}
+	if c.serverBlessings != nil {
+		patterns := append([]string{}, c.serverBlessings...)
+		o.ServerBlessings = &patterns
+	}
+	return o
+}
+
+// ProxyURL connects through the v23clientproxy app at url instead of
+// DefaultProxyURL.
+func ProxyURL(url string) Option {
+	return func(c *config) { c.proxyURL = url }
+}
+
+// ServerBlessings only allows calls to servers whose blessings match one of
+// patterns. By default, any server is allowed.
+func ServerBlessings(patterns ...string) Option {
+	return func(c *config) { c.serverBlessings = append(c.serverBlessings, patterns...) }
+}
+
+// CallTimeout limits each Vanadium call made for the connection to d.
+func CallTimeout(d time.Duration) Option {
+	return func(c *config) { c.callTimeout = d }
+}
+
+// Retry makes up to attempts calls when the server cannot be reached, or,
+// for methods marked [Idempotent], when a call fails with an error that allows
+// a retry. It waits backoff before the first retry and doubles it after each.
+func Retry(attempts int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.attempts = attempts
+		c.backoff = backoff
+	}
+}
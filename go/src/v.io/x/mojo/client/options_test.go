@@ -0,0 +1,44 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"mojom/v23clientproxy"
+)
+
+func TestOptions(t *testing.T) {
+	c := newConfig(nil)
+	if c.proxyURL != DefaultProxyURL {
+		t.Errorf("got proxy url %q, want %q", c.proxyURL, DefaultProxyURL)
+	}
+	if got, want := c.proxyOptions(), (v23clientproxy.ClientProxyOptions{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	c = newConfig([]Option{
+		ProxyURL("mojo:v23clientproxy"),
+		ServerBlessings("dev.v.io/u/alice"),
+		ServerBlessings("dev.v.io/u/bob"),
+		CallTimeout(2 * time.Second),
+		Retry(3, 50*time.Millisecond),
+	})
+	if got, want := c.proxyURL, "mojo:v23clientproxy"; got != want {
+		t.Errorf("got proxy url %q, want %q", got, want)
+	}
+	blessings := []string{"dev.v.io/u/alice", "dev.v.io/u/bob"}
+	want := v23clientproxy.ClientProxyOptions{
+		ServerBlessings: &blessings,
+		CallTimeoutMs:   2000,
+		MaxAttempts:     3,
+		RetryBackoffMs:  50,
+	}
+	if got := c.proxyOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command mojom2vdl generates VDL source for the types and interfaces of a
+// mojom file graph, as serialized by the mojom parser, e.g.:
+//
+//	mojom parse echo.mojom > echo.graph
+//	mojom2vdl -out=$VDLROOT echo.graph
+//
+// Values of the generated types have the VOM encoding that the v23 proxies use
+// for the mojom types, so Vanadium programs can call proxied mojo apps with
+// them. The file of package a/b/c is written to <out>/a/b/c/c.vdl.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/mojom_files"
+
+	"v.io/x/mojo/codegen"
+)
+
+var out = flag.String("out", ".", "directory under which the VDL packages are written")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: mojom2vdl [-out=dir] [graph file]")
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "mojom2vdl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run reads the file graph from file, or from stdin if file is empty.
+func run(file string) error {
+	var data []byte
+	var err error
+	if file == "" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	var graph mojom_files.MojomFileGraph
+	if err := graph.Decode(bindings.NewDecoder(data, nil)); err != nil {
+		return fmt.Errorf("cannot decode the file graph: %v", err)
+	}
+	files, err := codegen.MojomToVDL(nil, graph.ResolvedTypes)
+	if err != nil {
+		return err
+	}
+	for pkgPath, src := range files {
+		dir := filepath.Join(*out, filepath.FromSlash(pkgPath))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		name := filepath.Join(dir, filepath.Base(dir)+".vdl")
+		if err := ioutil.WriteFile(name, src, 0644); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command mojomcompat compares the interfaces of two versions of a mojom file
+// graph, as serialized by the mojom parser, and reports the changes that
+// break calls between clients and servers of different versions:
+//
+//	mojom parse old/echo.mojom > old.graph
+//	mojom parse new/echo.mojom > new.graph
+//	mojomcompat old.graph new.graph
+//
+// Interfaces are matched by their full identifier, e.g., mojo.examples.Echo.
+// The command exits with status 1 if any change is breaking. See
+// v.io/x/mojo/compat for the rules.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/mojom_files"
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/x/mojo/compat"
+)
+
+var iface = flag.String("interface", "", "full identifier of the interface to compare; by default every interface of the old graph is compared")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: mojomcompat [-interface=<full identifier>] <old graph file> <new graph file>")
+		os.Exit(2)
+	}
+	breaking, err := run(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mojomcompat: %v\n", err)
+		os.Exit(2)
+	}
+	if breaking {
+		os.Exit(1)
+	}
+}
+
+// run prints the changes between the interfaces of the graphs in oldFile and
+// newFile, and returns true if any of them is breaking.
+func run(oldFile, newFile string) (bool, error) {
+	oldGraph, err := readGraph(oldFile)
+	if err != nil {
+		return false, err
+	}
+	newGraph, err := readGraph(newFile)
+	if err != nil {
+		return false, err
+	}
+	oldIfaces, newIfaces := interfaces(oldGraph), interfaces(newGraph)
+	var names []string
+	if *iface != "" {
+		if _, ok := oldIfaces[*iface]; !ok {
+			return false, fmt.Errorf("%s has no interface %s", oldFile, *iface)
+		}
+		names = []string{*iface}
+	} else {
+		for name := range oldIfaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	breaking := false
+	for _, name := range names {
+		newIface, ok := newIfaces[name]
+		if !ok {
+			fmt.Printf("%s: breaking: interface removed\n", name)
+			breaking = true
+			continue
+		}
+		changes, err := compat.Check(oldIfaces[name], oldGraph.ResolvedTypes, newIface, newGraph.ResolvedTypes)
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, c := range changes {
+			fmt.Printf("%s: %v\n", name, c)
+		}
+		breaking = breaking || compat.IsBreaking(changes)
+	}
+	return breaking, nil
+}
+
+func readGraph(file string) (*mojom_files.MojomFileGraph, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var graph mojom_files.MojomFileGraph
+	if err := graph.Decode(bindings.NewDecoder(data, nil)); err != nil {
+		return nil, fmt.Errorf("cannot decode the file graph in %s: %v", file, err)
+	}
+	return &graph, nil
+}
+
+// interfaces maps the full identifiers of the interfaces in graph to their
+// descriptions.
+func interfaces(graph *mojom_files.MojomFileGraph) map[string]mojom_types.MojomInterface {
+	ifaces := map[string]mojom_types.MojomInterface{}
+	for _, udt := range graph.ResolvedTypes {
+		if i, ok := udt.(*mojom_types.UserDefinedTypeInterfaceType); ok && i.Value.DeclData != nil && i.Value.DeclData.FullIdentifier != nil {
+			ifaces[*i.Value.DeclData.FullIdentifier] = i.Value
+		}
+	}
+	return ifaces
+}
@@ -0,0 +1,125 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command mojominspect prints an annotated dump of mojom-encoded bytes, such
+// as those in transcoder errors, along with the value that they decode to.
+// The type of the bytes is given either as a VDL type:
+//
+//	mojominspect -type='struct{A int32; B string}' 1000000001000000...
+//
+// or as a mojom type in a file graph serialized by the mojom parser:
+//
+//	mojominspect -graph=echo.graph -mojom=mojo.examples.EchoRequest data.hex
+//
+// The bytes are read as hex from the argument, from a file or from stdin; hex
+// may contain spaces and newlines. With -raw, the file holds binary data.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/mojom_files"
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+	"v.io/x/ref/lib/vdl/build"
+	"v.io/x/ref/lib/vdl/compile"
+)
+
+var (
+	vdlType   = flag.String("type", "", "VDL type of the bytes")
+	graphFile = flag.String("graph", "", "serialized mojom file graph that defines -mojom")
+	mojomType = flag.String("mojom", "", "full identifier of the mojom type of the bytes")
+	raw       = flag.Bool("raw", false, "read binary rather than hex data")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() > 1 || (*vdlType == "") == (*mojomType == "") {
+		fmt.Fprintln(os.Stderr, "usage: mojominspect (-type=<vdl type> | -graph=<file> -mojom=<type>) [-raw] [hex | file]")
+		os.Exit(2)
+	}
+	t, defaults, err := parseType()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mojominspect: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := readData(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mojominspect: %v\n", err)
+		os.Exit(1)
+	}
+	dump, err := transcoder.Inspect(data, t, defaults)
+	fmt.Print(dump)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// parseType returns the type of the bytes, and the defaults of its struct
+// fields if it is a mojom type.
+func parseType() (*vdl.Type, transcoder.Defaults, error) {
+	if *vdlType != "" {
+		env := compile.NewEnv(-1)
+		values := build.BuildExprs("typeobject("+*vdlType+")", []*vdl.Type{vdl.TypeObjectType}, env)
+		if !env.Errors.IsEmpty() {
+			return nil, nil, fmt.Errorf("invalid type %q: %v", *vdlType, env.Errors)
+		}
+		return values[0].TypeObject(), nil, nil
+	}
+	data, err := ioutil.ReadFile(*graphFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var graph mojom_files.MojomFileGraph
+	if err := graph.Decode(bindings.NewDecoder(data, nil)); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the file graph: %v", err)
+	}
+	defaults, err := transcoder.MojomDefaults(graph.ResolvedTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, udt := range graph.ResolvedTypes {
+		s, ok := udt.(*mojom_types.UserDefinedTypeStructType)
+		if !ok || s.Value.DeclData.FullIdentifier == nil || *s.Value.DeclData.FullIdentifier != *mojomType {
+			continue
+		}
+		t, err := transcoder.MojomToVDLType(&mojom_types.TypeTypeReference{
+			mojom_types.TypeReference{TypeKey: &key},
+		}, graph.ResolvedTypes)
+		return t, defaults, err
+	}
+	return nil, nil, fmt.Errorf("struct %s is not in %s", *mojomType, *graphFile)
+}
+
+// readData returns the bytes in arg, which is either hex or a file name. If
+// arg is empty, the bytes are read from stdin.
+func readData(arg string) ([]byte, error) {
+	var input []byte
+	var err error
+	switch {
+	case arg == "":
+		input, err = ioutil.ReadAll(os.Stdin)
+	case !*raw && isHex(arg):
+		input = []byte(arg)
+	default:
+		input, err = ioutil.ReadFile(arg)
+	}
+	if err != nil || *raw {
+		return input, err
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(string(input)), ""))
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command v23gateway serves HTTP/JSON calls to Vanadium services, e.g., to
+// the mojo apps exported by a v23serverproxy, so that web dashboards can call
+// them:
+//
+//	v23gateway -names=<v23 name> &
+//	curl -H 'Content-Type: application/json' -d '{"value": "hello"}' localhost:8080/<v23 name>/EchoString
+//
+// The gateway calls services with its own blessings, so it only calls the
+// names given by -names and the names beneath them. See v.io/x/mojo/gateway
+// for the format of requests and responses.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+	"time"
+
+	"v.io/v23"
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/security/access"
+	"v.io/x/mojo/gateway"
+	_ "v.io/x/ref/runtime/factories/generic"
+)
+
+var (
+	addr    = flag.String("http", "localhost:8080", "address on which to serve HTTP")
+	timeout = flag.Duration("timeout", 30*time.Second, "timeout of each call; 0 means none")
+	servers = flag.String("server-blessings", "", "comma-separated blessing patterns that servers must match; by default any server is accepted")
+	names   = flag.String("names", "", "comma-separated Vanadium names that may be called, along with the names beneath them")
+)
+
+func main() {
+	ctx, shutdown := v23.Init()
+	defer shutdown()
+
+	if *names == "" {
+		ctx.Fatalf("no -names to call")
+	}
+	authorizer := security.AllowEveryone()
+	if *servers != "" {
+		var acl access.AccessList
+		for _, p := range strings.Split(*servers, ",") {
+			pattern := security.BlessingPattern(p)
+			if !pattern.IsValid() {
+				ctx.Fatalf("invalid server blessing pattern %q", p)
+			}
+			acl.In = append(acl.In, pattern)
+		}
+		authorizer = acl
+	}
+	ctx.Infof("Serving HTTP on %s", *addr)
+	err := http.ListenAndServe(*addr, &gateway.Handler{
+		Ctx:     ctx,
+		Timeout: *timeout,
+		Opts:    []rpc.CallOpt{options.ServerAuthorizer{authorizer}},
+		Names:   strings.Split(*names, ","),
+	})
+	ctx.Fatal(err)
+}
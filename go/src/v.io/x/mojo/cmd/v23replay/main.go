@@ -0,0 +1,99 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command v23replay repeats the calls in a capture file, written by a
+// v23clientproxy or v23serverproxy started with -capture, and reports the
+// calls whose results differ from the recorded ones:
+//
+//	v23replay -server=<v23serverproxy name> calls.capture
+//
+// Calls recorded by a v23clientproxy are repeated to the name they called.
+// Calls recorded by a v23serverproxy are repeated to the export they reached
+// on the server proxy named by -server, which may be a different one than
+// the one that recorded them. The command exits with status 1 if any call
+// differs.
+//
+// To repeat the mojom requests to a mojo app directly, e.g., in a test that
+// runs the app in-process, use capture.ReplayMojo.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/options"
+	"v.io/v23/security"
+	"v.io/x/mojo/proxy/capture"
+	_ "v.io/x/ref/runtime/factories/generic"
+)
+
+var (
+	serverName = flag.String("server", "", "Vanadium name of the v23serverproxy to which calls recorded by a server proxy are repeated")
+	proxyKind  = flag.String("proxy", "", "only repeat the calls recorded by this kind of proxy, client or server")
+	timeout    = flag.Duration("timeout", 30*time.Second, "timeout of each call; 0 means none")
+)
+
+func main() {
+	ctx, shutdown := v23.Init()
+	defer shutdown()
+
+	if flag.NArg() != 1 {
+		ctx.Fatal("usage: v23replay [flags] <capture file>")
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		ctx.Fatal(err)
+	}
+	records, err := capture.Read(f)
+	f.Close()
+	if err != nil {
+		ctx.Fatalf("reading %s: %v", flag.Arg(0), err)
+	}
+
+	var selected []capture.Record
+	for _, r := range records {
+		switch {
+		case *proxyKind != "" && r.Proxy != *proxyKind:
+		case r.Proxy == "server" && *serverName == "":
+			ctx.Fatal("the capture holds calls recorded by a server proxy; set -server or -proxy=client")
+		default:
+			selected = append(selected, r)
+		}
+	}
+	name := func(r capture.Record) string {
+		if r.Proxy == "server" {
+			return naming.Join(*serverName, r.Name)
+		}
+		return r.Name
+	}
+
+	differ := 0
+	for _, r := range selected {
+		var callCtx *context.T
+		var cancel context.CancelFunc
+		if *timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, *timeout)
+		} else {
+			callCtx, cancel = context.WithCancel(ctx)
+		}
+		result := capture.ReplayV23(callCtx, name, []capture.Record{r}, options.ServerAuthorizer{security.AllowEveryone()})[0]
+		cancel()
+		status := "ok"
+		if result.Diff != "" {
+			status = "DIFF " + result.Diff
+			differ++
+		}
+		fmt.Printf("%s %s %s.%s: %s\n", r.Time.Format(time.RFC3339Nano), r.Proxy, name(r), r.Method, status)
+	}
+	fmt.Printf("%d of %d calls differ\n", differ, len(selected))
+	if differ > 0 {
+		shutdown()
+		os.Exit(1)
+	}
+}
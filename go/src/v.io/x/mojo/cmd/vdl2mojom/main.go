@@ -0,0 +1,121 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command vdl2mojom generates mojom source for the types and interfaces of
+// VDL packages, e.g.:
+//
+//	vdl2mojom -out=mojom v.io/x/ref/services/stats
+//
+// Mojo apps can use the generated interfaces to call Vanadium services
+// through the SetupVanadiumProxy method of the v23 client proxy. The file of
+// the mojom module a.b.c is written to <out>/a.b.c.mojom.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/codegen"
+	"v.io/x/ref/lib/vdl/build"
+	"v.io/x/ref/lib/vdl/compile"
+)
+
+var out = flag.String("out", ".", "directory to which the mojom files are written")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: vdl2mojom [-out=dir] <vdl package>...")
+		os.Exit(2)
+	}
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "vdl2mojom: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string) error {
+	env := compile.NewEnv(-1)
+	pkgs := build.TransitivePackages(paths, build.UnknownPathIsError, build.Opts{}, env.Errors)
+	if !env.Errors.IsEmpty() {
+		return fmt.Errorf("%v", env.Errors)
+	}
+	// Only the requested packages are generated; the transitive ones are
+	// built for their types.
+	requested := map[string]bool{}
+	for _, path := range paths {
+		requested[path] = true
+	}
+	var types []*vdl.Type
+	var ifaces []signature.Interface
+	for _, pkg := range pkgs {
+		cpkg := build.BuildPackage(pkg, env)
+		if !env.Errors.IsEmpty() {
+			return fmt.Errorf("%v", env.Errors)
+		}
+		if !requested[pkg.Path] {
+			continue
+		}
+		for _, file := range cpkg.Files {
+			for _, def := range file.TypeDefs {
+				types = append(types, def.Type)
+			}
+			for _, iface := range file.Interfaces {
+				ifaces = append(ifaces, interfaceSignature(cpkg.Path, iface))
+			}
+		}
+	}
+	files, err := codegen.VDLToMojom(types, ifaces)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	for module, src := range files {
+		name := filepath.Join(*out, module+".mojom")
+		if err := ioutil.WriteFile(name, src, 0644); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// interfaceSignature returns the signature of iface, including the methods of
+// the interfaces that it embeds.
+func interfaceSignature(pkgPath string, iface *compile.Interface) signature.Interface {
+	sig := signature.Interface{Name: iface.Name, PkgPath: pkgPath}
+	for _, m := range iface.AllMethods() {
+		method := signature.Method{
+			Name:      m.Name,
+			InArgs:    args(m.InArgs),
+			OutArgs:   args(m.OutArgs),
+			InStream:  streamArg(m.InStream),
+			OutStream: streamArg(m.OutStream),
+		}
+		sig.Methods = append(sig.Methods, method)
+	}
+	return sig
+}
+
+func args(fields []*compile.Field) []signature.Arg {
+	args := make([]signature.Arg, len(fields))
+	for i, field := range fields {
+		args[i] = signature.Arg{Name: field.Name, Type: field.Type}
+	}
+	return args
+}
+
+func streamArg(t *vdl.Type) *signature.Arg {
+	if t == nil {
+		return nil
+	}
+	return &signature.Arg{Type: t}
+}
@@ -0,0 +1,402 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package codegen generates VDL source from mojom descriptors and back, so
+// that Vanadium programs can use the types of mojo apps and vice versa.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+)
+
+// MojomToVDL returns the VDL source of the types in desc and of iface, which
+// may be nil, keyed by VDL package path. The interfaces in desc are generated
+// too. Since the types are those that the transcoder derives from the mojom
+// types, their VOM encoding is what the proxies send over the wire; the
+// source must be placed at the package path for the type names to match.
+func MojomToVDL(iface *mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType) (map[string][]byte, error) {
+	defaults, err := transcoder.MojomDefaults(desc)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		desc:     desc,
+		packages: map[string]*vdlPackage{},
+		seen:     map[*vdl.Type]bool{},
+	}
+	keys := make([]string, 0, len(desc))
+	for key := range desc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := g.addUserDefinedType(key, desc[key]); err != nil {
+			return nil, err
+		}
+	}
+	if iface != nil {
+		if err := g.addInterface(*iface); err != nil {
+			return nil, err
+		}
+	}
+	files := map[string][]byte{}
+	for pkgPath, pkg := range g.packages {
+		files[pkgPath] = pkg.source(defaults)
+	}
+	return files, nil
+}
+
+type generator struct {
+	desc     map[string]mojom_types.UserDefinedType
+	packages map[string]*vdlPackage
+	seen     map[*vdl.Type]bool
+}
+
+// vdlPackage collects the definitions of a single VDL package.
+type vdlPackage struct {
+	path       string
+	types      []*vdl.Type
+	interfaces []vdlInterface
+	// interfaceNames holds the full identifiers of the generated interfaces.
+	interfaceNames map[string]bool
+}
+
+type vdlInterface struct {
+	name    string
+	methods []vdlMethod
+}
+
+type vdlMethod struct {
+	name    string
+	inArgs  []vdlArg
+	outArgs []vdlArg
+}
+
+type vdlArg struct {
+	name string
+	t    *vdl.Type
+}
+
+func (g *generator) pkg(pkgPath string) *vdlPackage {
+	p := g.packages[pkgPath]
+	if p == nil {
+		p = &vdlPackage{path: pkgPath, interfaceNames: map[string]bool{}}
+		g.packages[pkgPath] = p
+	}
+	return p
+}
+
+func (g *generator) addUserDefinedType(key string, udt mojom_types.UserDefinedType) error {
+	if u, ok := udt.(*mojom_types.UserDefinedTypeInterfaceType); ok {
+		return g.addInterface(u.Value)
+	}
+	var t *vdl.Type
+	err := catch(func() (err error) {
+		t, err = transcoder.MojomToVDLType(&mojom_types.TypeTypeReference{
+			mojom_types.TypeReference{TypeKey: &key},
+		}, g.desc)
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return g.addType(t)
+}
+
+// addType adds the named types that make up t to their packages.
+func (g *generator) addType(t *vdl.Type) error {
+	if g.seen[t] {
+		return nil
+	}
+	g.seen[t] = true
+	if t.Name() != "" {
+		pkgPath, name := vdl.SplitIdent(t.Name())
+		if pkgPath == "" {
+			return fmt.Errorf("type %s is not in a package", t.Name())
+		}
+		if !isExported(name) {
+			return fmt.Errorf("type %s cannot be exported from VDL", t.Name())
+		}
+		p := g.pkg(pkgPath)
+		p.types = append(p.types, t)
+	}
+	switch t.Kind() {
+	case vdl.Array, vdl.List, vdl.Optional:
+		return g.addType(t.Elem())
+	case vdl.Map:
+		if err := g.addType(t.Key()); err != nil {
+			return err
+		}
+		return g.addType(t.Elem())
+	case vdl.Struct, vdl.Union:
+		for i := 0; i < t.NumField(); i++ {
+			if err := g.addType(t.Field(i).Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) addInterface(mi mojom_types.MojomInterface) error {
+	if mi.DeclData == nil || mi.DeclData.FullIdentifier == nil {
+		return fmt.Errorf("interface %v has no identifier", mi.DeclData)
+	}
+	ident := *mi.DeclData.FullIdentifier
+	vdlName, err := transcoder.MojomToVDLTypeName(ident)
+	if err != nil {
+		return err
+	}
+	pkgPath, name := vdl.SplitIdent(vdlName)
+	if pkgPath == "" {
+		return fmt.Errorf("interface %s is not in a package", ident)
+	}
+	p := g.pkg(pkgPath)
+	if p.interfaceNames[ident] {
+		return nil
+	}
+	p.interfaceNames[ident] = true
+
+	ordinals := make([]int, 0, len(mi.Methods))
+	for ordinal := range mi.Methods {
+		ordinals = append(ordinals, int(ordinal))
+	}
+	sort.Ints(ordinals)
+	iface := vdlInterface{name: name}
+	for _, ordinal := range ordinals {
+		mm := mi.Methods[uint32(ordinal)]
+		m := vdlMethod{name: *mm.DeclData.ShortName}
+		if !isExported(m.name) {
+			return fmt.Errorf("method %s.%s cannot be exported from VDL", ident, m.name)
+		}
+		var err error
+		if m.inArgs, err = g.args(mm.Parameters); err != nil {
+			return fmt.Errorf("%s.%s: %v", ident, m.name, err)
+		}
+		if mm.ResponseParams != nil {
+			if m.outArgs, err = g.args(*mm.ResponseParams); err != nil {
+				return fmt.Errorf("%s.%s: %v", ident, m.name, err)
+			}
+		}
+		iface.methods = append(iface.methods, m)
+	}
+	p.interfaces = append(p.interfaces, iface)
+	return nil
+}
+
+// args returns the arguments that the proxies send for params, one per field.
+func (g *generator) args(params mojom_types.MojomStruct) ([]vdlArg, error) {
+	var t *vdl.Type
+	err := catch(func() (err error) {
+		t, err = transcoder.MojomStructToVDLType(params, g.desc)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	args := make([]vdlArg, len(params.Fields))
+	for i, field := range params.Fields {
+		args[i] = vdlArg{name: argName(*field.DeclData.ShortName), t: t.Field(i).Type}
+		if err := g.addType(args[i].t); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// catch calls f and returns its error. The transcoder panics on the mojom
+// types that VDL cannot represent; catch returns those panics as errors.
+func catch(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return f()
+}
+
+// source returns the VDL source of the package, whose structs have the given
+// mojom defaults.
+func (p *vdlPackage) source(defaults transcoder.Defaults) []byte {
+	imports := newImports(p.path, defaults)
+	var body bytes.Buffer
+	sort.Sort(typesByName(p.types))
+	for _, t := range p.types {
+		_, name := vdl.SplitIdent(t.Name())
+		fmt.Fprintf(&body, "\ntype %s %s\n", name, imports.baseTypeString(t))
+	}
+	for _, iface := range p.interfaces {
+		fmt.Fprintf(&body, "\ntype %s interface {\n", iface.name)
+		for _, m := range iface.methods {
+			fmt.Fprintf(&body, "\t%s(%s) ", m.name, imports.argsString(m.inArgs))
+			if len(m.outArgs) == 0 {
+				body.WriteString("error\n")
+			} else {
+				fmt.Fprintf(&body, "(%s | error)\n", imports.argsString(m.outArgs))
+			}
+		}
+		body.WriteString("}\n")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// This file was auto-generated by mojom2vdl.\n// Do not edit.\n\n")
+	fmt.Fprintf(&buf, "package %s\n", path.Base(p.path))
+	if len(imports.names) > 0 {
+		buf.WriteString("\nimport (\n")
+		for _, pkgPath := range imports.sorted() {
+			name := imports.names[pkgPath]
+			if name == path.Base(pkgPath) {
+				fmt.Fprintf(&buf, "\t%q\n", pkgPath)
+			} else {
+				fmt.Fprintf(&buf, "\t%s %q\n", name, pkgPath)
+			}
+		}
+		buf.WriteString(")\n")
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// imports tracks the packages that the source of package pkgPath refers to.
+type imports struct {
+	pkgPath  string
+	names    map[string]string // package path -> local name
+	used     map[string]bool   // local names in use
+	defaults transcoder.Defaults
+}
+
+func newImports(pkgPath string, defaults transcoder.Defaults) *imports {
+	return &imports{
+		pkgPath:  pkgPath,
+		names:    map[string]string{},
+		used:     map[string]bool{path.Base(pkgPath): true},
+		defaults: defaults,
+	}
+}
+
+func (im *imports) sorted() []string {
+	paths := make([]string, 0, len(im.names))
+	for pkgPath := range im.names {
+		paths = append(paths, pkgPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (im *imports) name(pkgPath string) string {
+	if name, ok := im.names[pkgPath]; ok {
+		return name
+	}
+	name := path.Base(pkgPath)
+	for i := 2; im.used[name]; i++ {
+		name = fmt.Sprintf("%s%d", path.Base(pkgPath), i)
+	}
+	im.names[pkgPath] = name
+	im.used[name] = true
+	return name
+}
+
+// typeString returns the VDL expression that refers to t.
+func (im *imports) typeString(t *vdl.Type) string {
+	if t.Name() == "" {
+		return im.baseTypeString(t)
+	}
+	pkgPath, name := vdl.SplitIdent(t.Name())
+	if pkgPath == im.pkgPath {
+		return name
+	}
+	return im.name(pkgPath) + "." + name
+}
+
+// baseTypeString returns the VDL expression that defines t.
+func (im *imports) baseTypeString(t *vdl.Type) string {
+	switch t.Kind() {
+	case vdl.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), im.typeString(t.Elem()))
+	case vdl.List:
+		return "[]" + im.typeString(t.Elem())
+	case vdl.Map:
+		return fmt.Sprintf("map[%s]%s", im.typeString(t.Key()), im.typeString(t.Elem()))
+	case vdl.Optional:
+		return "?" + im.typeString(t.Elem())
+	case vdl.Enum:
+		labels := make([]string, t.NumEnumLabel())
+		for i := range labels {
+			labels[i] = "\t" + t.EnumLabel(i) + "\n"
+		}
+		return "enum {\n" + strings.Join(labels, "") + "}"
+	case vdl.Struct, vdl.Union:
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%s {\n", t.Kind())
+		var defaults []*vdl.Value
+		if t.Kind() == vdl.Struct {
+			defaults = im.defaults.Fields(t)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if defaults != nil && defaults[i] != nil {
+				// VDL has no default values; the transcoder sets them
+				// when the field is absent from mojom data.
+				fmt.Fprintf(&buf, "\t// %s defaults to %v in mojom.\n", t.Field(i).Name, defaults[i])
+			}
+			fmt.Fprintf(&buf, "\t%s %s\n", t.Field(i).Name, im.typeString(t.Field(i).Type))
+		}
+		buf.WriteString("}")
+		return buf.String()
+	default:
+		// The remaining kinds that the transcoder produces are the built-in
+		// types, whose names are those of their kinds.
+		return t.Kind().String()
+	}
+}
+
+func (im *imports) argsString(args []vdlArg) string {
+	s := make([]string, len(args))
+	for i, arg := range args {
+		s[i] = arg.name + " " + im.typeString(arg.t)
+	}
+	return strings.Join(s, ", ")
+}
+
+type typesByName []*vdl.Type
+
+func (t typesByName) Len() int           { return len(t) }
+func (t typesByName) Less(i, j int) bool { return t[i].Name() < t[j].Name() }
+func (t typesByName) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// vdlKeywords are the words that cannot name arguments in VDL.
+var vdlKeywords = map[string]bool{
+	"const": true, "enum": true, "error": true, "import": true,
+	"interface": true, "map": true, "package": true, "set": true,
+	"stream": true, "struct": true, "type": true, "typeobject": true,
+	"union": true, "any": true, "bool": true, "byte": true,
+	"float32": true, "float64": true, "int8": true, "int16": true,
+	"int32": true, "int64": true, "string": true, "uint16": true,
+	"uint32": true, "uint64": true, "true": true, "false": true,
+	"nil": true,
+}
+
+// argName returns a VDL argument name for the mojom param name. Argument
+// names are not sent over the wire, so they may be changed freely.
+func argName(name string) string {
+	if vdlKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
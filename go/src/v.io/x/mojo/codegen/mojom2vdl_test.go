@@ -0,0 +1,209 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codegen_test
+
+import (
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/codegen"
+	"v.io/x/mojo/transcoder"
+	"v.io/x/ref/lib/vdl/build"
+	"v.io/x/ref/lib/vdl/compile"
+)
+
+const geoVDL = `// This file was auto-generated by mojom2vdl.
+// Do not edit.
+
+package geo
+
+import (
+	"geo/kinds"
+)
+
+type Point struct {
+	X int32
+	Y int32
+}
+
+type Shape struct {
+	Kind kinds.Kind
+	Points []Point
+	Center ?Point
+	Tags map[string]bool
+}
+
+type Geo interface {
+	Distance(a Point, b Point) (d float64 | error)
+	Reset() error
+}
+`
+
+const kindsVDL = `// This file was auto-generated by mojom2vdl.
+// Do not edit.
+
+package kinds
+
+type Kind enum {
+	Circle
+	Square
+}
+`
+
+func TestMojomToVDL(t *testing.T) {
+	pointType := vdl.NamedType("geo.Point", vdl.StructType(vdl.Field{"X", vdl.Int32Type}, vdl.Field{"Y", vdl.Int32Type}))
+	shapeType := vdl.NamedType("geo.Shape", vdl.StructType(
+		vdl.Field{"Kind", vdl.NamedType("geo/kinds.Kind", vdl.EnumType("Circle", "Square"))},
+		vdl.Field{"Points", vdl.ListType(pointType)},
+		vdl.Field{"Center", vdl.OptionalType(pointType)},
+		vdl.Field{"Tags", vdl.MapType(vdl.StringType, vdl.BoolType)},
+	))
+	iface, desc, err := transcoder.VDLInterfaceToMojomInterface(signature.Interface{
+		Name:    "Geo",
+		PkgPath: "geo",
+		Methods: []signature.Method{
+			{
+				Name:    "Distance",
+				InArgs:  []signature.Arg{{Name: "a", Type: pointType}, {Name: "b", Type: pointType}},
+				OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float64Type}},
+			},
+			{Name: "Reset"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, shapeDesc, err := transcoder.VDLToMojomTypeE(shapeType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, udt := range shapeDesc {
+		desc[key] = udt
+	}
+
+	files, err := codegen.MojomToVDL(&iface, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(files), 2; got != want {
+		t.Errorf("got %d packages, want %d", got, want)
+	}
+	if got, want := string(files["geo"]), geoVDL; got != want {
+		t.Errorf("got geo:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := string(files["geo/kinds"]), kindsVDL; got != want {
+		t.Errorf("got geo/kinds:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The generated VDL must compile to the types the transcoder sends.
+	pkgs := compileVDL(t, files, "geo/kinds", "geo")
+	for key := range desc {
+		key := key
+		want, err := transcoder.MojomToVDLType(&mojom_types.TypeTypeReference{
+			mojom_types.TypeReference{TypeKey: &key},
+		}, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgPath, name := vdl.SplitIdent(want.Name())
+		def := pkgs[pkgPath].ResolveType(name)
+		if def == nil {
+			t.Errorf("%s: no type %s in the generated VDL", key, want.Name())
+			continue
+		}
+		if got := def.Type; got != want {
+			t.Errorf("%s: got compiled type %v, want %v", key, got, want)
+		}
+	}
+	geo := pkgs["geo"].ResolveInterface("Geo")
+	if geo == nil {
+		t.Fatalf("no interface Geo in the generated VDL")
+	}
+	for _, method := range geo.Methods {
+		if method.Name != "Distance" {
+			continue
+		}
+		for i, arg := range method.InArgs {
+			if got, want := arg.Type, pointType; got != want {
+				t.Errorf("Distance arg %d: got %v, want %v", i, got, want)
+			}
+		}
+		if got, want := method.OutArgs[0].Type, vdl.Float64Type; got != want {
+			t.Errorf("Distance result: got %v, want %v", got, want)
+		}
+	}
+}
+
+// compileVDL compiles the generated VDL files of the packages with the given
+// paths, in order, and returns the compiled packages keyed by path.
+func compileVDL(t *testing.T, files map[string][]byte, paths ...string) map[string]*compile.Package {
+	env := compile.NewEnv(-1)
+	pkgs := map[string]*compile.Package{}
+	for _, pkgPath := range paths {
+		src := files[pkgPath]
+		pkg := &build.Package{
+			Name:          path.Base(pkgPath),
+			Path:          pkgPath,
+			GenPath:       pkgPath,
+			BaseFileNames: []string{path.Base(pkgPath) + ".vdl"},
+			OpenFilesFunc: func(names []string) (map[string]io.ReadCloser, error) {
+				readers := map[string]io.ReadCloser{}
+				for _, name := range names {
+					readers[name] = ioutil.NopCloser(strings.NewReader(string(src)))
+				}
+				return readers, nil
+			},
+		}
+		pkgs[pkgPath] = build.BuildPackage(pkg, env)
+		if !env.Errors.IsEmpty() {
+			t.Fatalf("compiling %s: %v\n%s", pkgPath, env.Errors, src)
+		}
+	}
+	return pkgs
+}
+
+func TestMojomToVDLUnsupported(t *testing.T) {
+	ident, field := "geo.Pipe", "pipe"
+	desc := map[string]mojom_types.UserDefinedType{
+		"TYPE_KEY:geo.Pipe": &mojom_types.UserDefinedTypeStructType{mojom_types.MojomStruct{
+			DeclData: &mojom_types.DeclarationData{FullIdentifier: &ident},
+			Fields: []mojom_types.StructField{{
+				DeclData: &mojom_types.DeclarationData{ShortName: &field},
+				Type:     &mojom_types.TypeHandleType{mojom_types.HandleType{Kind: mojom_types.HandleType_Kind_MessagePipe}},
+			}},
+		}},
+	}
+	if _, err := codegen.MojomToVDL(nil, desc); err == nil {
+		t.Errorf("generated VDL for a struct with a handle")
+	}
+}
+
+func TestMojomToVDLDefaults(t *testing.T) {
+	ident, field := "geo.Style", "width"
+	desc := map[string]mojom_types.UserDefinedType{
+		"TYPE_KEY:geo.Style": &mojom_types.UserDefinedTypeStructType{mojom_types.MojomStruct{
+			DeclData: &mojom_types.DeclarationData{FullIdentifier: &ident},
+			Fields: []mojom_types.StructField{{
+				DeclData:     &mojom_types.DeclarationData{ShortName: &field},
+				Type:         &mojom_types.TypeSimpleType{mojom_types.SimpleType_Int32},
+				DefaultValue: &mojom_types.DefaultFieldValueValue{&mojom_types.ValueLiteralValue{&mojom_types.LiteralValueInt32Value{2}}},
+			}},
+		}},
+	}
+	files, err := codegen.MojomToVDL(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(files["geo"]), "\t// Width defaults to "; !strings.Contains(got, want) {
+		t.Errorf("got geo:\n%s\nwant a comment with the default of Width", got)
+	}
+}
@@ -0,0 +1,366 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/transcoder"
+)
+
+// VDLToMojom returns the mojom source of the struct, union and enum types in
+// types and of the interfaces in ifaces, keyed by mojom module name. Other
+// named VDL types have no mojom counterpart; they are inlined where they are
+// used. The methods of each interface are ordered by name, so the ordinals
+// match those that the v23 client proxy assigns when a mojo app calls a
+// Vanadium service through SetupVanadiumProxy.
+func VDLToMojom(types []*vdl.Type, ifaces []signature.Interface) (map[string][]byte, error) {
+	desc := map[string]mojom_types.UserDefinedType{}
+	for _, t := range types {
+		switch t.Kind() {
+		case vdl.Struct, vdl.Union, vdl.Enum:
+		default:
+			continue
+		}
+		_, mp, err := transcoder.VDLToMojomTypeE(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.Name(), err)
+		}
+		for key, udt := range mp {
+			desc[key] = udt
+		}
+	}
+	mojomIfaces := make([]mojom_types.MojomInterface, len(ifaces))
+	for i, iface := range ifaces {
+		iface.Methods = append([]signature.Method(nil), iface.Methods...)
+		transcoder.SortMethods(iface.Methods)
+		mi, mp, err := transcoder.VDLInterfaceToMojomInterface(iface)
+		if err != nil {
+			return nil, err
+		}
+		for key, udt := range mp {
+			desc[key] = udt
+		}
+		mojomIfaces[i] = mi
+	}
+	return MojomSource(mojomIfaces, desc)
+}
+
+// MojomSource returns the mojom source that declares ifaces and the types in
+// desc, keyed by module name. The file of module a.b.c is meant to be saved
+// as a.b.c.mojom, which is how the files import each other.
+func MojomSource(ifaces []mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType) (map[string][]byte, error) {
+	p := &printer{desc: desc, modules: map[string]*mojomModule{}}
+	keys := make([]string, 0, len(desc))
+	for key := range desc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := p.addUserDefinedType(desc[key]); err != nil {
+			return nil, err
+		}
+	}
+	for _, iface := range ifaces {
+		if err := p.addInterface(iface); err != nil {
+			return nil, err
+		}
+	}
+	files := map[string][]byte{}
+	for name, m := range p.modules {
+		files[name] = m.source()
+	}
+	return files, nil
+}
+
+type printer struct {
+	desc    map[string]mojom_types.UserDefinedType
+	modules map[string]*mojomModule
+}
+
+// mojomModule collects the declarations of a single mojom module.
+type mojomModule struct {
+	name    string
+	imports map[string]bool
+	decls   map[string]string // short name -> declaration
+}
+
+func (p *printer) module(ident string) (*mojomModule, string, error) {
+	lastDot := strings.LastIndex(ident, ".")
+	if lastDot == -1 {
+		return nil, "", fmt.Errorf("%s is not in a module", ident)
+	}
+	name, short := ident[:lastDot], ident[lastDot+1:]
+	for _, part := range strings.Split(name, ".") {
+		if !transcoder.IsMojomIdentifier(part) {
+			return nil, "", fmt.Errorf("%s is not a valid mojom module name", name)
+		}
+	}
+	m := p.modules[name]
+	if m == nil {
+		m = &mojomModule{name: name, imports: map[string]bool{}, decls: map[string]string{}}
+		p.modules[name] = m
+	}
+	return m, short, nil
+}
+
+func (p *printer) addUserDefinedType(udt mojom_types.UserDefinedType) error {
+	var buf bytes.Buffer
+	var m *mojomModule
+	var name string
+	var err error
+	switch u := udt.(type) {
+	case *mojom_types.UserDefinedTypeStructType:
+		if m, name, err = p.module(*u.Value.DeclData.FullIdentifier); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "struct %s {\n", name)
+		for _, field := range u.Value.Fields {
+			if err := checkMemberName(name, *field.DeclData.ShortName); err != nil {
+				return err
+			}
+			t, err := p.typeString(m, field.Type)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", name, *field.DeclData.ShortName, err)
+			}
+			fmt.Fprintf(&buf, "  %s %s;\n", t, *field.DeclData.ShortName)
+		}
+	case *mojom_types.UserDefinedTypeUnionType:
+		if m, name, err = p.module(*u.Value.DeclData.FullIdentifier); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "union %s {\n", name)
+		for _, field := range u.Value.Fields {
+			if err := checkMemberName(name, *field.DeclData.ShortName); err != nil {
+				return err
+			}
+			t, err := p.typeString(m, field.Type)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", name, *field.DeclData.ShortName, err)
+			}
+			fmt.Fprintf(&buf, "  %s %s@%d;\n", t, *field.DeclData.ShortName, field.Tag)
+		}
+	case *mojom_types.UserDefinedTypeEnumType:
+		if m, name, err = p.module(*u.Value.DeclData.FullIdentifier); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "enum %s {\n", name)
+		for _, v := range u.Value.Values {
+			if err := checkMemberName(name, *v.DeclData.ShortName); err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "  %s = %d,\n", *v.DeclData.ShortName, v.IntValue)
+		}
+	case *mojom_types.UserDefinedTypeInterfaceType:
+		return p.addInterface(u.Value)
+	default:
+		return fmt.Errorf("user defined type %#v with unknown tag %d", udt, udt.Tag())
+	}
+	buf.WriteString("};\n")
+	m.decls[name] = buf.String()
+	return nil
+}
+
+func (p *printer) addInterface(mi mojom_types.MojomInterface) error {
+	if mi.DeclData == nil || mi.DeclData.FullIdentifier == nil {
+		return fmt.Errorf("interface %v has no identifier", mi.DeclData)
+	}
+	m, name, err := p.module(*mi.DeclData.FullIdentifier)
+	if err != nil {
+		return err
+	}
+	ordinals := make([]int, 0, len(mi.Methods))
+	for ordinal := range mi.Methods {
+		ordinals = append(ordinals, int(ordinal))
+	}
+	sort.Ints(ordinals)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "interface %s {\n", name)
+	for _, ordinal := range ordinals {
+		mm := mi.Methods[uint32(ordinal)]
+		params, err := p.paramsString(m, mm.Parameters)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, *mm.DeclData.ShortName, err)
+		}
+		fmt.Fprintf(&buf, "  %s@%d(%s)", *mm.DeclData.ShortName, ordinal, params)
+		if mm.ResponseParams != nil {
+			response, err := p.paramsString(m, *mm.ResponseParams)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", name, *mm.DeclData.ShortName, err)
+			}
+			fmt.Fprintf(&buf, " => (%s)", response)
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("};\n")
+	m.decls[name] = buf.String()
+	return nil
+}
+
+func (p *printer) paramsString(m *mojomModule, params mojom_types.MojomStruct) (string, error) {
+	s := make([]string, len(params.Fields))
+	for i, field := range params.Fields {
+		t, err := p.typeString(m, field.Type)
+		if err != nil {
+			return "", err
+		}
+		s[i] = t + " " + paramName(*field.DeclData.ShortName)
+	}
+	return strings.Join(s, ", "), nil
+}
+
+// typeString returns the mojom expression of t in module m.
+func (p *printer) typeString(m *mojomModule, t mojom_types.Type) (string, error) {
+	var s string
+	nullable := false
+	switch t := t.(type) {
+	case *mojom_types.TypeSimpleType:
+		s = simpleTypeNames[t.Value]
+	case *mojom_types.TypeStringType:
+		s, nullable = "string", t.Value.Nullable
+	case *mojom_types.TypeArrayType:
+		elem, err := p.typeString(m, t.Value.ElementType)
+		if err != nil {
+			return "", err
+		}
+		if t.Value.FixedLength > 0 {
+			s = fmt.Sprintf("array<%s, %d>", elem, t.Value.FixedLength)
+		} else {
+			s = fmt.Sprintf("array<%s>", elem)
+		}
+		nullable = t.Value.Nullable
+	case *mojom_types.TypeMapType:
+		key, err := p.typeString(m, t.Value.KeyType)
+		if err != nil {
+			return "", err
+		}
+		value, err := p.typeString(m, t.Value.ValueType)
+		if err != nil {
+			return "", err
+		}
+		s, nullable = fmt.Sprintf("map<%s, %s>", key, value), t.Value.Nullable
+	case *mojom_types.TypeTypeReference:
+		udt, ok := p.desc[*t.Value.TypeKey]
+		if !ok {
+			return "", fmt.Errorf("unknown type %s", *t.Value.TypeKey)
+		}
+		ident := *udtDeclData(udt).FullIdentifier
+		refModule, name, err := p.module(ident)
+		if err != nil {
+			return "", err
+		}
+		if refModule == m {
+			s = name
+		} else {
+			m.imports[refModule.name] = true
+			s = ident
+		}
+		nullable = t.Value.Nullable
+	default:
+		return "", fmt.Errorf("type %#v is not supported", t)
+	}
+	if nullable {
+		s += "?"
+	}
+	return s, nil
+}
+
+func udtDeclData(udt mojom_types.UserDefinedType) *mojom_types.DeclarationData {
+	switch u := udt.(type) {
+	case *mojom_types.UserDefinedTypeStructType:
+		return u.Value.DeclData
+	case *mojom_types.UserDefinedTypeUnionType:
+		return u.Value.DeclData
+	case *mojom_types.UserDefinedTypeEnumType:
+		return u.Value.DeclData
+	case *mojom_types.UserDefinedTypeInterfaceType:
+		return u.Value.DeclData
+	}
+	return nil
+}
+
+// source returns the mojom source of the module.
+func (m *mojomModule) source() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// This file was auto-generated by vdl2mojom.\n// Do not edit.\n\n")
+	fmt.Fprintf(&buf, "module %s;\n", m.name)
+	if len(m.imports) > 0 {
+		buf.WriteString("\n")
+		for _, name := range sortedKeys(m.imports) {
+			fmt.Fprintf(&buf, "import %q;\n", name+".mojom")
+		}
+	}
+	names := make([]string, 0, len(m.decls))
+	for name := range m.decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString("\n")
+		buf.WriteString(m.decls[name])
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var simpleTypeNames = map[mojom_types.SimpleType]string{
+	mojom_types.SimpleType_Bool:   "bool",
+	mojom_types.SimpleType_Double: "double",
+	mojom_types.SimpleType_Float:  "float",
+	mojom_types.SimpleType_Int8:   "int8",
+	mojom_types.SimpleType_Int16:  "int16",
+	mojom_types.SimpleType_Int32:  "int32",
+	mojom_types.SimpleType_Int64:  "int64",
+	mojom_types.SimpleType_Uint8:  "uint8",
+	mojom_types.SimpleType_Uint16: "uint16",
+	mojom_types.SimpleType_Uint32: "uint32",
+	mojom_types.SimpleType_Uint64: "uint64",
+}
+
+// mojomKeywords are the words that cannot name parameters, fields or enum
+// labels in mojom.
+var mojomKeywords = map[string]bool{
+	"import": true, "module": true, "struct": true, "union": true,
+	"interface": true, "enum": true, "const": true, "true": true,
+	"false": true, "default": true, "handle": true, "array": true,
+	"map": true, "string": true, "bool": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "float": true,
+	"double": true,
+}
+
+// paramName returns a mojom parameter name for the VDL argument name. Params
+// are encoded by position, so their names may be changed freely.
+func paramName(name string) string {
+	if mojomKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// checkMemberName returns an error if name, the name of a field or label of
+// the mojom type typeName, is a mojom keyword. Unlike params, members cannot
+// be renamed, since their names must map back to the VDL names.
+func checkMemberName(typeName, name string) error {
+	if mojomKeywords[name] {
+		return fmt.Errorf("%s.%s: %q is a mojom keyword", typeName, name, name)
+	}
+	return nil
+}
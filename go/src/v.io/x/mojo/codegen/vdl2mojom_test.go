@@ -0,0 +1,103 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codegen_test
+
+import (
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/codegen"
+)
+
+const geoMojom = `// This file was auto-generated by vdl2mojom.
+// Do not edit.
+
+module geo;
+
+import "geo.kinds.mojom";
+
+interface Geo {
+  Distance@0(Point a, Point b) => (double d);
+  Reset@1() => ();
+};
+
+struct Point {
+  int32 x;
+  int32 y;
+};
+
+struct Shape {
+  geo.kinds.Kind kind;
+  array<Point> points;
+  Point? center;
+  map<string, bool> tags;
+};
+`
+
+const kindsMojom = `// This file was auto-generated by vdl2mojom.
+// Do not edit.
+
+module geo.kinds;
+
+enum Kind {
+  CIRCLE = 0,
+  SQUARE = 1,
+};
+`
+
+func TestVDLToMojom(t *testing.T) {
+	pointType := vdl.NamedType("geo.Point", vdl.StructType(vdl.Field{"X", vdl.Int32Type}, vdl.Field{"Y", vdl.Int32Type}))
+	shapeType := vdl.NamedType("geo.Shape", vdl.StructType(
+		vdl.Field{"Kind", vdl.NamedType("geo/kinds.Kind", vdl.EnumType("Circle", "Square"))},
+		vdl.Field{"Points", vdl.ListType(pointType)},
+		vdl.Field{"Center", vdl.OptionalType(pointType)},
+		vdl.Field{"Tags", vdl.MapType(vdl.StringType, vdl.BoolType)},
+	))
+	// Named types without a mojom counterpart are skipped.
+	types := []*vdl.Type{shapeType, pointType, vdl.NamedType("geo.Name", vdl.StringType)}
+	ifaces := []signature.Interface{{
+		Name:    "Geo",
+		PkgPath: "geo",
+		Methods: []signature.Method{
+			{Name: "Reset"},
+			{
+				Name:    "Distance",
+				InArgs:  []signature.Arg{{Name: "a", Type: pointType}, {Name: "b", Type: pointType}},
+				OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float64Type}},
+			},
+		},
+	}}
+
+	files, err := codegen.VDLToMojom(types, ifaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(files), 2; got != want {
+		t.Errorf("got %d modules, want %d", got, want)
+	}
+	if got, want := string(files["geo"]), geoMojom; got != want {
+		t.Errorf("got geo:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := string(files["geo.kinds"]), kindsMojom; got != want {
+		t.Errorf("got geo.kinds:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := codegen.VDLToMojom([]*vdl.Type{vdl.NamedType("geo.Any", vdl.StructType(vdl.Field{"V", vdl.AnyType}))}, nil); err == nil {
+		t.Errorf("generated mojom for a struct with an any field")
+	}
+}
+
+func TestVDLToMojomKeywords(t *testing.T) {
+	for _, bad := range []*vdl.Type{
+		vdl.NamedType("geo.Label", vdl.StructType(vdl.Field{"String", vdl.StringType})),
+		vdl.NamedType("geo.Index", vdl.StructType(vdl.Field{"Map", vdl.MapType(vdl.StringType, vdl.Int32Type)})),
+		vdl.NamedType("geo.Value", vdl.UnionType(vdl.Field{"Handle", vdl.Int32Type})),
+	} {
+		if _, err := codegen.VDLToMojom([]*vdl.Type{bad}, nil); err == nil {
+			t.Errorf("generated mojom for %v, whose field is a mojom keyword", bad)
+		}
+	}
+}
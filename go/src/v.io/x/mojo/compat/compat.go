@@ -0,0 +1,344 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package compat compares two versions of a mojom interface and reports the
+// changes that break calls between peers that use different versions, e.g.,
+// a client and a server that ship on different release trains.
+//
+// Both versions are converted to VDL types by the transcoder, so a change is
+// reported as breaking exactly when the transcoder, or the Vanadium
+// conversion between the types of the two versions, would fail or lose data
+// for a call in either direction: an old client calling a new server, or a
+// new client calling an old server. In particular:
+//
+//   - Mojom lays out struct fields by declaration order, so fields may not be
+//     removed, reordered or inserted. Fields may be appended if, following
+//     the mojom versioning rules, they carry a [MinVersion] that is greater
+//     than that of every field of the old version; a peer that receives a
+//     struct without them sets them to their default value.
+//   - The request and response params of a method are sent as separate
+//     arguments, which are matched by position, so params may not be added
+//     or removed, even at the end.
+//   - Vanadium converts structs, unions and enums by field and label name, so
+//     renamed fields and labels are breaking, while renamed types are not.
+//   - Unions and enums are encoded by tag, so added fields and labels break
+//     old peers, which cannot decode them.
+//   - Methods are called by name over Vanadium and by ordinal over mojo
+//     pipes, so removed methods and changed ordinals are breaking.
+package compat
+
+import (
+	"fmt"
+	"sort"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+)
+
+// Severity classifies a change.
+type Severity int
+
+const (
+	// Safe changes keep peers of both versions interoperable.
+	Safe Severity = iota
+	// Breaking changes make some calls between peers of different versions
+	// fail or lose data.
+	Breaking
+)
+
+func (s Severity) String() string {
+	if s == Breaking {
+		return "breaking"
+	}
+	return "safe"
+}
+
+// Change describes a difference between two versions of an interface.
+type Change struct {
+	Severity Severity
+	// Path locates the change, e.g., "Method", "Method.request.Field" or
+	// "Method.response.Field[]" for the elements of a list.
+	Path    string
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Severity, c.Path, c.Message)
+}
+
+// IsBreaking returns true if any of changes is breaking.
+func IsBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Severity == Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Check compares the old and new versions of an interface, whose types are
+// described by oldDesc and newDesc, and returns the changes ordered by path.
+// A type that is used in several places is compared once, at its first use.
+func Check(oldIface mojom_types.MojomInterface, oldDesc map[string]mojom_types.UserDefinedType, newIface mojom_types.MojomInterface, newDesc map[string]mojom_types.UserDefinedType) ([]Change, error) {
+	c := &checker{
+		seen:       map[[2]*vdl.Type]bool{},
+		oldStructs: structsByName(oldDesc),
+		newStructs: structsByName(newDesc),
+	}
+	oldMethods, newMethods := methodsByName(oldIface), methodsByName(newIface)
+	var names []string
+	for name := range oldMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		oldOrdinal := oldMethods[name]
+		newOrdinal, ok := newMethods[name]
+		if !ok {
+			c.add(Breaking, name, "method removed")
+			continue
+		}
+		if oldOrdinal != newOrdinal {
+			c.add(Breaking, name, fmt.Sprintf("ordinal changed from %d to %d", oldOrdinal, newOrdinal))
+		}
+		oldMethod, newMethod := oldIface.Methods[oldOrdinal], newIface.Methods[newOrdinal]
+		if err := c.params(name+".request", &oldMethod.Parameters, oldDesc, &newMethod.Parameters, newDesc); err != nil {
+			return nil, err
+		}
+		switch {
+		case oldMethod.ResponseParams == nil && newMethod.ResponseParams != nil:
+			c.add(Breaking, name, "response added")
+		case oldMethod.ResponseParams != nil && newMethod.ResponseParams == nil:
+			c.add(Breaking, name, "response removed")
+		default:
+			if err := c.params(name+".response", oldMethod.ResponseParams, oldDesc, newMethod.ResponseParams, newDesc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name := range newMethods {
+		if _, ok := oldMethods[name]; !ok {
+			c.add(Safe, name, "method added")
+		}
+	}
+	sort.Stable(byPath(c.changes))
+	return c.changes, nil
+}
+
+// methodsByName maps the names of the methods of iface to their ordinals.
+func methodsByName(iface mojom_types.MojomInterface) map[string]uint32 {
+	methods := map[string]uint32{}
+	for ordinal, m := range iface.Methods {
+		methods[*m.DeclData.ShortName] = ordinal
+	}
+	return methods
+}
+
+// structsByName maps the VDL names of the structs in desc to the structs.
+func structsByName(desc map[string]mojom_types.UserDefinedType) map[string]mojom_types.MojomStruct {
+	structs := map[string]mojom_types.MojomStruct{}
+	for _, udt := range desc {
+		st, ok := udt.(*mojom_types.UserDefinedTypeStructType)
+		if !ok || st.Value.DeclData == nil || st.Value.DeclData.FullIdentifier == nil {
+			continue
+		}
+		if name, err := transcoder.MojomToVDLTypeName(*st.Value.DeclData.FullIdentifier); err == nil {
+			structs[name] = st.Value
+		}
+	}
+	return structs
+}
+
+type byPath []Change
+
+func (c byPath) Len() int           { return len(c) }
+func (c byPath) Less(i, j int) bool { return c[i].Path < c[j].Path }
+func (c byPath) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+type checker struct {
+	changes []Change
+	// seen holds the pairs of types that have been compared, so that
+	// recursive types are compared once.
+	seen map[[2]*vdl.Type]bool
+	// oldStructs and newStructs map the VDL names of the structs of each
+	// version to their mojom declarations, which hold the [MinVersion] of
+	// their fields.
+	oldStructs, newStructs map[string]mojom_types.MojomStruct
+}
+
+func (c *checker) add(s Severity, path, message string) {
+	c.changes = append(c.changes, Change{Severity: s, Path: path, Message: message})
+}
+
+// params compares the request or response parameters of a method.
+func (c *checker) params(path string, oldParams *mojom_types.MojomStruct, oldDesc map[string]mojom_types.UserDefinedType, newParams *mojom_types.MojomStruct, newDesc map[string]mojom_types.UserDefinedType) error {
+	if oldParams == nil || newParams == nil {
+		return nil
+	}
+	oldType, err := transcoder.MojomStructToVDLType(*oldParams, oldDesc)
+	if err != nil {
+		return fmt.Errorf("%s: old version: %v", path, err)
+	}
+	newType, err := transcoder.MojomStructToVDLType(*newParams, newDesc)
+	if err != nil {
+		return fmt.Errorf("%s: new version: %v", path, err)
+	}
+	if oldType != newType {
+		c.fields(path, oldType, newType, "parameter")
+	}
+	return nil
+}
+
+// types compares the old and new versions of a type.
+func (c *checker) types(path string, oldType, newType *vdl.Type) {
+	if oldType == newType || c.seen[[2]*vdl.Type{oldType, newType}] {
+		return
+	}
+	c.seen[[2]*vdl.Type{oldType, newType}] = true
+	if oldType.Name() != newType.Name() && oldType.Name() != "" && newType.Name() != "" {
+		c.add(Safe, path, fmt.Sprintf("type renamed from %s to %s", oldType.Name(), newType.Name()))
+	}
+	if oldType.Kind() != newType.Kind() {
+		c.add(Breaking, path, fmt.Sprintf("type changed from %v to %v", oldType, newType))
+		return
+	}
+	switch oldType.Kind() {
+	case vdl.Optional:
+		c.types(path, oldType.Elem(), newType.Elem())
+	case vdl.Array:
+		if oldType.Len() != newType.Len() {
+			c.add(Breaking, path, fmt.Sprintf("array length changed from %d to %d", oldType.Len(), newType.Len()))
+		}
+		c.types(path+"[]", oldType.Elem(), newType.Elem())
+	case vdl.List:
+		c.types(path+"[]", oldType.Elem(), newType.Elem())
+	case vdl.Map:
+		c.types(path+"[key]", oldType.Key(), newType.Key())
+		c.types(path+"[]", oldType.Elem(), newType.Elem())
+	case vdl.Struct:
+		c.fields(path, oldType, newType, "field")
+	case vdl.Union:
+		c.fields(path, oldType, newType, "union field")
+	case vdl.Enum:
+		c.labels(path, oldType, newType)
+	}
+}
+
+// fields compares the fields of two structs or unions, or the params of a
+// method, which are matched by position: mojom lays out struct fields and
+// tags union fields by their order of declaration, and params are sent as
+// separate arguments. what is "field", "union field" or "parameter".
+func (c *checker) fields(path string, oldType, newType *vdl.Type, what string) {
+	n := oldType.NumField()
+	if newType.NumField() < n {
+		n = newType.NumField()
+	}
+	for i := 0; i < n; i++ {
+		oldField, newField := oldType.Field(i), newType.Field(i)
+		if oldField.Name != newField.Name {
+			c.add(Breaking, path+"."+oldField.Name, fmt.Sprintf("%s %d renamed or reordered to %s", what, i, newField.Name))
+		}
+		c.types(path+"."+oldField.Name, oldField.Type, newField.Type)
+	}
+	for i := n; i < oldType.NumField(); i++ {
+		c.add(Breaking, path+"."+oldType.Field(i).Name, what+" removed")
+	}
+	for i := n; i < newType.NumField(); i++ {
+		fieldPath := path + "." + newType.Field(i).Name
+		switch what {
+		case "field":
+			c.appendedField(fieldPath, oldType, newType, i)
+		case "parameter":
+			c.add(Breaking, fieldPath, "parameter added; params are sent by position, so their number must not change")
+		default:
+			c.add(Breaking, fieldPath, what+" added; old peers cannot decode it")
+		}
+	}
+}
+
+// MinVersionAttribute is the mojom attribute that gives the version of a
+// struct in which a field was added.
+const MinVersionAttribute = "MinVersion"
+
+// appendedField checks field i of newType, which the old version lacks,
+// against the mojom versioning rules: its [MinVersion] must exceed that of
+// every field of the old version, and must not be less than that of the field
+// before it.
+func (c *checker) appendedField(path string, oldType, newType *vdl.Type, i int) {
+	version, ok := minVersion(c.newStructs, newType, i)
+	if !ok {
+		c.add(Breaking, path, "field appended without [MinVersion]; old peers reject the larger struct")
+		return
+	}
+	var oldVersion int64
+	for j := 0; j < oldType.NumField(); j++ {
+		if v, ok := minVersion(c.oldStructs, oldType, j); ok && v > oldVersion {
+			oldVersion = v
+		}
+	}
+	if version <= oldVersion {
+		c.add(Breaking, path, fmt.Sprintf("field appended with [MinVersion=%d], which does not exceed the version %d of the old struct", version, oldVersion))
+		return
+	}
+	if prev, ok := minVersion(c.newStructs, newType, i-1); ok && version < prev {
+		c.add(Breaking, path, fmt.Sprintf("field appended with [MinVersion=%d], which is less than the %d of the field before it", version, prev))
+		return
+	}
+	c.add(Safe, path, "field appended; it takes its default value when old peers leave it out")
+}
+
+// minVersion returns the [MinVersion] of field i of the struct t, whose mojom
+// declaration is in structs.
+func minVersion(structs map[string]mojom_types.MojomStruct, t *vdl.Type, i int) (int64, bool) {
+	ms, ok := structs[t.Name()]
+	if !ok || i < 0 || i >= len(ms.Fields) {
+		return 0, false
+	}
+	decl := ms.Fields[i].DeclData
+	if decl == nil || decl.Attributes == nil {
+		return 0, false
+	}
+	for _, attr := range *decl.Attributes {
+		if attr.Key != MinVersionAttribute {
+			continue
+		}
+		switch v := attr.Value.(type) {
+		case *mojom_types.LiteralValueInt8Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueInt16Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueInt32Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueInt64Value:
+			return v.Value, true
+		case *mojom_types.LiteralValueUint8Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueUint16Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueUint32Value:
+			return int64(v.Value), true
+		case *mojom_types.LiteralValueUint64Value:
+			return int64(v.Value), true
+		}
+	}
+	return 0, false
+}
+
+// labels compares the labels of two enums, which are encoded by their value.
+func (c *checker) labels(path string, oldType, newType *vdl.Type) {
+	for i := 0; i < oldType.NumEnumLabel(); i++ {
+		label := oldType.EnumLabel(i)
+		switch {
+		case i >= newType.NumEnumLabel():
+			c.add(Breaking, path, fmt.Sprintf("enum value %s removed", label))
+		case newType.EnumLabel(i) != label:
+			c.add(Breaking, path, fmt.Sprintf("enum value %d renamed from %s to %s", i, label, newType.EnumLabel(i)))
+		}
+	}
+	for i := oldType.NumEnumLabel(); i < newType.NumEnumLabel(); i++ {
+		c.add(Breaking, path, fmt.Sprintf("enum value %s added; old peers cannot decode it", newType.EnumLabel(i)))
+	}
+}
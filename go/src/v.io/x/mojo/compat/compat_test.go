@@ -0,0 +1,296 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compat_test
+
+import (
+	"reflect"
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/compat"
+	"v.io/x/mojo/transcoder"
+)
+
+func geoInterface(t *testing.T, methods ...signature.Method) (mojom_types.MojomInterface, map[string]mojom_types.UserDefinedType) {
+	mi, mp, err := transcoder.VDLInterfaceToMojomInterface(signature.Interface{
+		Name:    "Geo",
+		PkgPath: "geo",
+		Methods: methods,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mi, mp
+}
+
+// setMinVersion gives field i of the struct ident in desc a [MinVersion].
+func setMinVersion(t *testing.T, desc map[string]mojom_types.UserDefinedType, ident string, i int, version int32) {
+	for _, udt := range desc {
+		st, ok := udt.(*mojom_types.UserDefinedTypeStructType)
+		if !ok || *st.Value.DeclData.FullIdentifier != ident {
+			continue
+		}
+		st.Value.Fields[i].DeclData.Attributes = &[]mojom_types.Attribute{{
+			Key:   compat.MinVersionAttribute,
+			Value: &mojom_types.LiteralValueInt32Value{version},
+		}}
+		return
+	}
+	t.Fatalf("no struct %s", ident)
+}
+
+func safe(path, message string) compat.Change {
+	return compat.Change{Severity: compat.Safe, Path: path, Message: message}
+}
+
+func breaking(path, message string) compat.Change {
+	return compat.Change{Severity: compat.Breaking, Path: path, Message: message}
+}
+
+func TestCheck(t *testing.T) {
+	point := func(name string) *vdl.Type {
+		return vdl.NamedType(name, vdl.StructType(vdl.Field{"X", vdl.Int32Type}, vdl.Field{"Y", vdl.Int32Type}))
+	}
+	oldPoint, newPoint := point("geo.Point"), point("geo.Coord")
+	oldKind := vdl.NamedType("geo.Kind", vdl.EnumType("Circle", "Square"))
+	newKind := vdl.NamedType("geo.Kind", vdl.EnumType("Circle", "Square", "Triangle"))
+	oldShape := vdl.NamedType("geo.Shape", vdl.StructType(
+		vdl.Field{"Kind", oldKind},
+		vdl.Field{"Points", vdl.ListType(oldPoint)},
+	))
+	newShape := vdl.NamedType("geo.Shape", vdl.StructType(
+		vdl.Field{"Kind", newKind},
+		vdl.Field{"Points", vdl.ListType(newPoint)},
+		vdl.Field{"Color", vdl.StringType},
+	))
+
+	oldIface, oldDesc := geoInterface(t,
+		signature.Method{
+			Name:    "Distance",
+			InArgs:  []signature.Arg{{Name: "a", Type: oldPoint}, {Name: "b", Type: oldPoint}},
+			OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float64Type}},
+		},
+		signature.Method{Name: "Reset"},
+		signature.Method{
+			Name:    "Draw",
+			InArgs:  []signature.Arg{{Name: "s", Type: oldShape}},
+			OutArgs: []signature.Arg{{Name: "ok", Type: vdl.BoolType}},
+		},
+	)
+	newIface, newDesc := geoInterface(t,
+		signature.Method{
+			Name:    "Distance",
+			InArgs:  []signature.Arg{{Name: "a", Type: newPoint}, {Name: "b", Type: newPoint}},
+			OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float32Type}},
+		},
+		signature.Method{
+			Name:    "Draw",
+			InArgs:  []signature.Arg{{Name: "s", Type: newShape}},
+			OutArgs: []signature.Arg{{Name: "ok", Type: vdl.BoolType}},
+		},
+		signature.Method{Name: "Reset"},
+		signature.Method{Name: "Clear"},
+	)
+	setMinVersion(t, newDesc, "geo.Shape", 2, 1)
+
+	changes, err := compat.Check(oldIface, oldDesc, newIface, newDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []compat.Change{
+		safe("Clear", "method added"),
+		safe("Distance.request.A", "type renamed from geo.Point to geo.Coord"),
+		breaking("Distance.response.D", "type changed from float64 to float32"),
+		breaking("Draw", "ordinal changed from 2 to 1"),
+		safe("Draw.request.S.Color", "field appended; it takes its default value when old peers leave it out"),
+		breaking("Draw.request.S.Kind", "enum value Triangle added; old peers cannot decode it"),
+		breaking("Reset", "ordinal changed from 1 to 2"),
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes")
+		for _, c := range changes {
+			t.Errorf("\t%v", c)
+		}
+		t.Errorf("want")
+		for _, c := range want {
+			t.Errorf("\t%v", c)
+		}
+	}
+	if !compat.IsBreaking(changes) {
+		t.Errorf("IsBreaking returned false")
+	}
+
+	// The reverse direction removes what was added.
+	changes, err = compat.Check(newIface, newDesc, oldIface, oldDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Path == "Clear" && (c.Severity != compat.Breaking || c.Message != "method removed") {
+			t.Errorf("got %v, want a removed method", c)
+		}
+	}
+
+	if changes, err := compat.Check(oldIface, oldDesc, oldIface, oldDesc); err != nil || len(changes) != 0 {
+		t.Errorf("comparing an interface with itself: got %v, %v, want no changes", changes, err)
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	check := func(oldType, newType *vdl.Type) []compat.Change {
+		oldIface, oldDesc := geoInterface(t, signature.Method{Name: "M", InArgs: []signature.Arg{{Name: "v", Type: oldType}}})
+		newIface, newDesc := geoInterface(t, signature.Method{Name: "M", InArgs: []signature.Arg{{Name: "v", Type: newType}}})
+		changes, err := compat.Check(oldIface, oldDesc, newIface, newDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+	s := func(fields ...vdl.Field) *vdl.Type { return vdl.NamedType("geo.S", vdl.StructType(fields...)) }
+	u := func(fields ...vdl.Field) *vdl.Type { return vdl.NamedType("geo.U", vdl.UnionType(fields...)) }
+	tests := []struct {
+		oldType, newType *vdl.Type
+		want             []compat.Change
+	}{
+		{
+			s(vdl.Field{"A", vdl.Int32Type}, vdl.Field{"B", vdl.StringType}),
+			s(vdl.Field{"B", vdl.StringType}, vdl.Field{"A", vdl.Int32Type}),
+			[]compat.Change{
+				breaking("M.request.V.A", "field 0 renamed or reordered to B"),
+				breaking("M.request.V.A", "type changed from int32 to string"),
+				breaking("M.request.V.B", "field 1 renamed or reordered to A"),
+				breaking("M.request.V.B", "type changed from string to int32"),
+			},
+		},
+		{
+			s(vdl.Field{"A", vdl.Int32Type}, vdl.Field{"B", vdl.StringType}),
+			s(vdl.Field{"A", vdl.Int32Type}),
+			[]compat.Change{breaking("M.request.V.B", "field removed")},
+		},
+		{
+			u(vdl.Field{"A", vdl.Int32Type}),
+			u(vdl.Field{"A", vdl.Int32Type}, vdl.Field{"B", vdl.StringType}),
+			[]compat.Change{breaking("M.request.V.B", "union field added; old peers cannot decode it")},
+		},
+		{
+			s(vdl.Field{"A", vdl.ListType(vdl.Int32Type)}),
+			s(vdl.Field{"A", vdl.StringType}),
+			[]compat.Change{breaking("M.request.V.A", "type changed from []int32 to string")},
+		},
+		{
+			s(vdl.Field{"A", vdl.ArrayType(2, vdl.Int32Type)}),
+			s(vdl.Field{"A", vdl.ArrayType(3, vdl.Int32Type)}),
+			[]compat.Change{breaking("M.request.V.A", "array length changed from 2 to 3")},
+		},
+		{
+			vdl.NamedType("geo.E", vdl.EnumType("A", "B")),
+			vdl.NamedType("geo.E", vdl.EnumType("A", "C")),
+			[]compat.Change{breaking("M.request.V", "enum value 1 renamed from B to C")},
+		},
+	}
+	for _, test := range tests {
+		if got := check(test.oldType, test.newType); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v -> %v: got %v, want %v", test.oldType, test.newType, got, test.want)
+		}
+	}
+}
+
+func TestCheckMinVersion(t *testing.T) {
+	s := func(fields ...vdl.Field) *vdl.Type { return vdl.NamedType("geo.S", vdl.StructType(fields...)) }
+	v1 := s(vdl.Field{"A", vdl.Int32Type}, vdl.Field{"B", vdl.StringType})
+	v2 := s(vdl.Field{"A", vdl.Int32Type}, vdl.Field{"B", vdl.StringType}, vdl.Field{"C", vdl.BoolType}, vdl.Field{"D", vdl.BoolType})
+	tests := []struct {
+		// old and new hold the [MinVersion] of each field of v1 and v2; 0
+		// means none.
+		old, new []int32
+		want     []compat.Change
+	}{
+		{
+			[]int32{0, 0}, []int32{0, 0, 1, 1},
+			[]compat.Change{
+				safe("M.request.V.C", "field appended; it takes its default value when old peers leave it out"),
+				safe("M.request.V.D", "field appended; it takes its default value when old peers leave it out"),
+			},
+		},
+		{
+			[]int32{0, 0}, []int32{0, 0, 0, 1},
+			[]compat.Change{
+				breaking("M.request.V.C", "field appended without [MinVersion]; old peers reject the larger struct"),
+				safe("M.request.V.D", "field appended; it takes its default value when old peers leave it out"),
+			},
+		},
+		{
+			[]int32{0, 2}, []int32{0, 2, 2, 3},
+			[]compat.Change{
+				breaking("M.request.V.C", "field appended with [MinVersion=2], which does not exceed the version 2 of the old struct"),
+				safe("M.request.V.D", "field appended; it takes its default value when old peers leave it out"),
+			},
+		},
+		{
+			[]int32{0, 0}, []int32{0, 0, 3, 2},
+			[]compat.Change{
+				safe("M.request.V.C", "field appended; it takes its default value when old peers leave it out"),
+				breaking("M.request.V.D", "field appended with [MinVersion=2], which is less than the 3 of the field before it"),
+			},
+		},
+	}
+	for _, test := range tests {
+		// The struct is an argument, not the params themselves, so that
+		// appended fields can be safe.
+		oldIface, oldDesc := geoInterface(t, signature.Method{Name: "M", InArgs: []signature.Arg{{Name: "v", Type: v1}}})
+		newIface, newDesc := geoInterface(t, signature.Method{Name: "M", InArgs: []signature.Arg{{Name: "v", Type: v2}}})
+		for i, version := range test.old {
+			if version != 0 {
+				setMinVersion(t, oldDesc, "geo.S", i, version)
+			}
+		}
+		for i, version := range test.new {
+			if version != 0 {
+				setMinVersion(t, newDesc, "geo.S", i, version)
+			}
+		}
+		changes, err := compat.Check(oldIface, oldDesc, newIface, newDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(changes, test.want) {
+			t.Errorf("%v -> %v: got %v, want %v", test.old, test.new, changes, test.want)
+		}
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	one := []signature.Arg{{Name: "a", Type: vdl.Int32Type}}
+	two := []signature.Arg{{Name: "a", Type: vdl.Int32Type}, {Name: "b", Type: vdl.StringType}}
+	tests := []struct {
+		oldArgs, newArgs []signature.Arg
+		want             []compat.Change
+	}{
+		{one, two, []compat.Change{breaking("M.request.B", "parameter added; params are sent by position, so their number must not change")}},
+		{two, one, []compat.Change{breaking("M.request.B", "parameter removed")}},
+		{one, one, nil},
+	}
+	for _, test := range tests {
+		oldIface, oldDesc := geoInterface(t, signature.Method{Name: "M", InArgs: test.oldArgs})
+		newIface, newDesc := geoInterface(t, signature.Method{Name: "M", InArgs: test.newArgs})
+		// Even with a [MinVersion], params may not be appended.
+		if len(test.newArgs) == 2 {
+			newIface.Methods[0].Parameters.Fields[1].DeclData.Attributes = &[]mojom_types.Attribute{{
+				Key:   compat.MinVersionAttribute,
+				Value: &mojom_types.LiteralValueInt32Value{1},
+			}}
+		}
+		changes, err := compat.Check(oldIface, oldDesc, newIface, newDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(changes, test.want) {
+			t.Errorf("%v -> %v: got %v, want %v", test.oldArgs, test.newArgs, changes, test.want)
+		}
+	}
+}
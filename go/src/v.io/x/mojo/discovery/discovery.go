@@ -0,0 +1,123 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package discovery advertises the mojo services exported by a v23serverproxy
+// through Vanadium discovery, so that clients on the same network can find
+// them without being told the proxy's endpoint.
+//
+// Each export is advertised as a discovery service whose InterfaceName is the
+// mojom interface name, whose InstanceName is the export name and whose
+// addresses are the v23 names at which the export can be called. The mojo url
+// is carried in the AttrMojoURL attribute.
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/discovery"
+	"v.io/v23/naming"
+	"v.io/x/mojo/proxy/exports"
+)
+
+// AttrMojoURL is the attribute holding the url of the mojo app that serves
+// the advertised interface.
+const AttrMojoURL = "mojo_url"
+
+// Service returns the discovery service that describes e, served at the
+// given endpoint names.
+func Service(e exports.Export, endpoints []string) discovery.Service {
+	addrs := make([]string, len(endpoints))
+	for i, ep := range endpoints {
+		addrs[i] = naming.Join(ep, e.Name)
+	}
+	return discovery.Service{
+		InstanceName:  e.Name,
+		InterfaceName: e.Interface,
+		Attrs:         discovery.Attributes{AttrMojoURL: e.URL},
+		Addrs:         addrs,
+	}
+}
+
+// ErrStopped is returned by Advertise after StopAll.
+var ErrStopped = errors.New("discovery: advertiser is stopped")
+
+// Advertiser keeps one advertisement per export.
+type Advertiser struct {
+	ctx       *context.T
+	d         discovery.T
+	endpoints func() []string
+
+	// mu is held while advertisements are started and stopped, so that
+	// concurrent calls for the same export cannot leave one behind.
+	mu      sync.Mutex
+	ads     map[string]*advertisement // keyed by export name
+	stopped bool
+}
+
+type advertisement struct {
+	cancel func()
+	done   <-chan struct{}
+}
+
+// NewAdvertiser returns an Advertiser that advertises through d. endpoints
+// is called for every new advertisement and returns the names at which the
+// proxy is currently reachable.
+func NewAdvertiser(ctx *context.T, d discovery.T, endpoints func() []string) *Advertiser {
+	return &Advertiser{
+		ctx:       ctx,
+		d:         d,
+		endpoints: endpoints,
+		ads:       map[string]*advertisement{},
+	}
+}
+
+// Advertise starts advertising e, replacing any earlier advertisement for
+// the same export name. It fails with ErrStopped after StopAll.
+func (a *Advertiser) Advertise(e exports.Export) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopped {
+		return ErrStopped
+	}
+	a.stop(e.Name)
+	ctx, cancel := context.WithCancel(a.ctx)
+	service := Service(e, a.endpoints())
+	done, err := a.d.Advertise(ctx, &service, nil)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("advertising %q failed: %v", e.Name, err)
+	}
+	a.ads[e.Name] = &advertisement{cancel, done}
+	return nil
+}
+
+// Stop stops advertising the export called name, if it is advertised, and
+// waits for the advertisement to be withdrawn.
+func (a *Advertiser) Stop(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stop(name)
+}
+
+func (a *Advertiser) stop(name string) {
+	if ad := a.ads[name]; ad != nil {
+		delete(a.ads, name)
+		ad.cancel()
+		<-ad.done
+	}
+}
+
+// StopAll stops every advertisement. Later calls to Advertise fail, e.g.,
+// for exports added after the proxy stopped listening.
+func (a *Advertiser) StopAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopped = true
+	for name := range a.ads {
+		a.stop(name)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discovery_test
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	vdiscovery "v.io/v23/discovery"
+	"v.io/x/mojo/discovery"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/util"
+	idiscovery "v.io/x/ref/lib/discovery"
+	"v.io/x/ref/lib/discovery/plugins/mock"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+var echo = exports.Export{
+	Name: "echo",
+	Address: util.Address{
+		URL:       "https://mojo.v.io/echo_server.mojo",
+		Interface: "mojo::examples::RemoteEcho",
+	},
+}
+
+// scanOne returns the next update for iface, or nil after a timeout.
+func scanOne(t *testing.T, ctx *context.T, d vdiscovery.T, iface string) vdiscovery.Update {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates, err := d.Scan(ctx, `v.InterfaceName="`+iface+`"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case u := <-updates:
+		return u
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
+
+func TestAdvertiser(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	d := idiscovery.NewWithPlugins([]idiscovery.Plugin{mock.New()})
+	defer d.Close()
+	a := discovery.NewAdvertiser(ctx, d, func() []string { return []string{"/@5@tcp@127.0.0.1:1234@@@@s@@@"} })
+
+	if err := a.Advertise(echo); err != nil {
+		t.Fatal(err)
+	}
+	u, ok := scanOne(t, ctx, d, echo.Interface).(vdiscovery.UpdateFound)
+	if !ok {
+		t.Fatalf("no advertisement found for %s", echo.Interface)
+	}
+	got := u.Value.Service
+	want := discovery.Service(echo, []string{"/@5@tcp@127.0.0.1:1234@@@@s@@@"})
+	if got.InstanceName != want.InstanceName || got.InterfaceName != want.InterfaceName ||
+		!reflect.DeepEqual(got.Attrs, want.Attrs) || !reflect.DeepEqual(got.Addrs, want.Addrs) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := got.Addrs[0], "/@5@tcp@127.0.0.1:1234@@@@s@@@/echo"; got != want {
+		t.Errorf("got address %q, want %q", got, want)
+	}
+
+	a.Stop(echo.Name)
+	if u := scanOne(t, ctx, d, echo.Interface); u != nil {
+		if _, ok := u.(vdiscovery.UpdateFound); ok {
+			t.Errorf("advertisement still found after Stop: %v", u)
+		}
+	}
+}
+
+func TestAdvertiseConcurrently(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	d := idiscovery.NewWithPlugins([]idiscovery.Plugin{mock.New()})
+	defer d.Close()
+	a := discovery.NewAdvertiser(ctx, d, func() []string { return []string{"/@5@tcp@127.0.0.1:1234@@@@s@@@"} })
+
+	// Each Advertise replaces the advertisement of the one before, so a
+	// single Stop withdraws them all.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.Advertise(echo); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	a.Stop(echo.Name)
+	services, err := discovery.Scan(ctx, d, echo.Interface, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 0 {
+		t.Errorf("found %v after Stop", services)
+	}
+
+	a.StopAll()
+	if err := a.Advertise(echo); err != discovery.ErrStopped {
+		t.Errorf("Advertise after StopAll: got error %v, want %v", err, discovery.ErrStopped)
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	d := idiscovery.NewWithPlugins([]idiscovery.Plugin{mock.New()})
+	defer d.Close()
+	a := discovery.NewAdvertiser(ctx, d, func() []string { return []string{"/@5@tcp@127.0.0.1:1234@@@@s@@@"} })
+	defer a.StopAll()
+
+	fortune := exports.Export{
+		Name: "fortune",
+		Address: util.Address{
+			URL:       "https://mojo.v.io/fortune_server.mojo",
+			Interface: "mojo::examples::Fortune",
+		},
+	}
+	echo2 := echo
+	echo2.Name = "echo2"
+	for _, e := range []exports.Export{echo, echo2, fortune} {
+		if err := a.Advertise(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := discovery.Scan(ctx, d, echo.Interface, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range services {
+		names = append(names, s.InstanceName)
+	}
+	sort.Strings(names)
+	if got, want := names, []string{"echo", "echo2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"strconv"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/discovery"
+)
+
+// Scan returns the services advertised for the mojom interface iface that are
+// found within timeout, in the order in which they were found. Services that
+// are lost again before the timeout are left out.
+func Scan(ctx *context.T, d discovery.T, iface string, timeout time.Duration) ([]discovery.Service, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	updates, err := d.Scan(ctx, "v.InterfaceName="+strconv.Quote(iface))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := map[string]bool{}
+	found := map[string]discovery.Service{}
+	for u := range updates {
+		switch u := u.(type) {
+		case discovery.UpdateFound:
+			s := u.Value.Service
+			if !seen[s.InstanceId] {
+				seen[s.InstanceId] = true
+				ids = append(ids, s.InstanceId)
+			}
+			found[s.InstanceId] = s
+		case discovery.UpdateLost:
+			delete(found, u.Value.InstanceId)
+		}
+	}
+
+	services := make([]discovery.Service, 0, len(found))
+	for _, id := range ids {
+		if s, ok := found[id]; ok {
+			services = append(services, s)
+		}
+	}
+	return services, nil
+}
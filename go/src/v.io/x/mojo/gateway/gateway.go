@@ -0,0 +1,259 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gateway lets HTTP clients that speak JSON, such as web dashboards,
+// call Vanadium services, including the mojo apps served by v23serverproxy.
+//
+// A call is a request
+//
+//	POST /<v23 name>/<method>
+//
+// whose body is a JSON object that holds the arguments of the method, keyed
+// by argument name. Arguments without a name in the signature of the service
+// are named arg0, arg1, ... by position, and missing arguments are zero. The
+// response is a JSON object that holds the results in the same way. Values
+// have the JSON form of transcoder.JSONToValue. Rooted names keep their
+// leading slash, e.g., POST //example.com:8101/echo/EchoString.
+//
+// Failed calls get a JSON object {"id": <verror id>, "error": <message>} and
+// an HTTP status that reflects the error, e.g., 404 for unknown names and
+// methods or 403 when access is denied.
+//
+// Calls are made with the blessings of the gateway, so the Handler only
+// serves what a browser cannot be tricked into sending from another site:
+// the Content-Type of a request must be application/json, and a request with
+// an Origin header must come from the host of the gateway itself. It also
+// only calls the names in Handler.Names, and bounds the size of request
+// bodies.
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/rpc/reserved"
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/v23/verror"
+	"v.io/x/mojo/transcoder"
+)
+
+// Handler serves calls to Vanadium services over HTTP.
+type Handler struct {
+	// Ctx is the context in which calls are made.
+	Ctx *context.T
+	// Timeout bounds each call, including the signature lookup; 0 means no
+	// timeout.
+	Timeout time.Duration
+	// Opts are passed to every call, e.g., options.ServerAuthorizer.
+	Opts []rpc.CallOpt
+	// Names are the Vanadium names that may be called, along with the names
+	// beneath them, e.g., "a/b" allows calls to "a/b" and "a/b/c" but not to
+	// "a/bc". No name may be called if Names is empty.
+	Names []string
+	// MaxBodySize bounds the size of request bodies in bytes; 0 means
+	// DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// DefaultMaxBodySize is the bound on the size of request bodies of a Handler
+// without a MaxBodySize.
+const DefaultMaxBodySize = 1 << 20
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, "", fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "", fmt.Errorf("content type %q is not application/json", r.Header.Get("Content-Type")))
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !sameHost(origin, r.Host) {
+		writeError(w, http.StatusForbidden, "", fmt.Errorf("cross-origin requests from %s are not allowed", origin))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	if slash <= 0 || slash == len(path)-1 {
+		writeError(w, http.StatusNotFound, "", fmt.Errorf("path %q is not /<v23 name>/<method>", r.URL.Path))
+		return
+	}
+	name, method := path[:slash], path[slash+1:]
+	if !h.allowed(name) {
+		writeError(w, http.StatusForbidden, verror.ErrNoAccess.ID, fmt.Errorf("calls to %s are not allowed", name))
+		return
+	}
+	max := h.MaxBodySize
+	if max == 0 {
+		max = DefaultMaxBodySize
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	ctx := h.Ctx
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	sig, err := reserved.MethodSignature(ctx, name, method, h.Opts...)
+	if err != nil {
+		writeVError(w, err)
+		return
+	}
+	args, err := decodeArgs(body, sig.InArgs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "", err)
+		return
+	}
+	results := make([]*vdl.Value, len(sig.OutArgs))
+	resultptrs := make([]interface{}, len(results))
+	for i := range results {
+		resultptrs[i] = &results[i]
+	}
+	if err := v23.GetClient(ctx).Call(ctx, name, method, args, resultptrs, h.Opts...); err != nil {
+		writeVError(w, err)
+		return
+	}
+	response, err := encodeResults(results, sig.OutArgs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// allowed returns true if name is one of h.Names or beneath one of them.
+func (h *Handler) allowed(name string) bool {
+	for _, allowed := range h.Names {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if name == allowed || strings.HasPrefix(name, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sameHost returns true if origin, the value of an Origin header, names host.
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == host
+}
+
+// argName returns the name of the i'th argument in a request or response.
+func argName(arg signature.Arg, i int) string {
+	if arg.Name == "" {
+		return fmt.Sprintf("arg%d", i)
+	}
+	return arg.Name
+}
+
+// decodeArgs converts the JSON object in body to the arguments of a call.
+func decodeArgs(body []byte, inArgs []signature.Arg) ([]interface{}, error) {
+	fields := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, fmt.Errorf("the body is not a JSON object: %v", err)
+		}
+	}
+	args := make([]interface{}, len(inArgs))
+	for i, arg := range inArgs {
+		name := argName(arg, i)
+		raw, ok := fields[name]
+		if !ok {
+			args[i] = vdl.ZeroValue(arg.Type)
+			continue
+		}
+		delete(fields, name)
+		// The types come from a Vanadium signature, which has no mojom
+		// defaults.
+		v, err := transcoder.JSONToValue(raw, arg.Type, nil)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %v", name, err)
+		}
+		args[i] = v
+	}
+	for name := range fields {
+		return nil, fmt.Errorf("unknown argument %s", name)
+	}
+	return args, nil
+}
+
+// encodeResults returns the JSON object that holds results.
+func encodeResults(results []*vdl.Value, outArgs []signature.Arg) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, v := range results {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, _ := json.Marshal(argName(outArgs[i], i))
+		buf.Write(name)
+		buf.WriteString(":")
+		if v == nil {
+			v = vdl.ZeroValue(outArgs[i].Type)
+		}
+		value, err := transcoder.ValueToJSON(v)
+		if err != nil {
+			return nil, fmt.Errorf("result %s: %v", name, err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// statusCodes maps the ids of Vanadium errors to HTTP status codes. Other
+// errors are reported as 500 Internal Server Error.
+var statusCodes = map[verror.ID]int{
+	verror.ErrBadArg.ID:            http.StatusBadRequest,
+	verror.ErrBadProtocol.ID:       http.StatusBadRequest,
+	verror.ErrNoAccess.ID:          http.StatusForbidden,
+	verror.ErrNotTrusted.ID:        http.StatusForbidden,
+	verror.ErrNoExist.ID:           http.StatusNotFound,
+	verror.ErrNoExistOrNoAccess.ID: http.StatusNotFound,
+	verror.ErrUnknownMethod.ID:     http.StatusNotFound,
+	verror.ErrUnknownSuffix.ID:     http.StatusNotFound,
+	verror.ErrExist.ID:             http.StatusConflict,
+	verror.ErrNotImplemented.ID:    http.StatusNotImplemented,
+	verror.ErrNoServers.ID:         http.StatusBadGateway,
+	verror.ErrCanceled.ID:          http.StatusServiceUnavailable,
+	verror.ErrTimeout.ID:           http.StatusGatewayTimeout,
+}
+
+// writeVError writes err, returned by a Vanadium call.
+func writeVError(w http.ResponseWriter, err error) {
+	id := verror.ErrorID(err)
+	status, ok := statusCodes[id]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeError(w, status, id, err)
+}
+
+func writeError(w http.ResponseWriter, status int, id verror.ID, err error) {
+	body, _ := json.Marshal(struct {
+		ID    verror.ID `json:"id"`
+		Error string    `json:"error"`
+	}{id, err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/x/mojo/gateway"
+	"v.io/x/mojo/server"
+	"v.io/x/mojo/tests/impl"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+func TestGateway(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	_, s, err := server.Serve(ctx, "", security.AllowEveryone(), server.Options{},
+		server.Service{
+			Name:    "test",
+			Factory: impl.ServiceFactory(),
+		},
+		server.Service{
+			Name:    "restricted",
+			Factory: impl.ServiceFactory(),
+			Methods: []string{"MultiArgs"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := s.Status().Endpoints[0].Name()
+	h := &gateway.Handler{
+		Ctx:   ctx,
+		Opts:  []rpc.CallOpt{options.ServerAuthorizer{security.AllowEveryone()}},
+		Names: []string{ep + "/test", ep + "/restricted", ep + "/nothing"},
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{
+			"POST", "/test/Simple", `{"a": 123}`,
+			http.StatusOK, `{"value":"TheValue"}`,
+		},
+		{
+			"POST", "/test/MultiArgs", `{"a": true, "b": [1, 2, 3], "c": {"X": 1, "Y": 2}, "d": {"X": 3, "Y": 300, "Z": 129}}`,
+			http.StatusOK, `{"x":{"B":"TheUnion"},"y":"yresponse"}`,
+		},
+		{
+			"POST", "/restricted/MultiArgs", `{"a": true, "b": [1, 2, 3], "c": {"X": 1, "Y": 2}, "d": {"X": 3, "Y": 300, "Z": 129}}`,
+			http.StatusOK, `{"x":{"B":"TheUnion"},"y":"yresponse"}`,
+		},
+		// The mojo app rejects the argument.
+		{"POST", "/test/Simple", `{"a": 1}`, http.StatusInternalServerError, ""},
+		{"POST", "/test/Simple", `{"a": "x"}`, http.StatusBadRequest, ""},
+		{"POST", "/test/Simple", `{"b": 1}`, http.StatusBadRequest, ""},
+		{"POST", "/test/Unknown", `{}`, http.StatusNotFound, ""},
+		{"POST", "/restricted/Simple", `{"a": 123}`, http.StatusNotFound, ""},
+		{"POST", "/nothing/Simple", `{"a": 123}`, http.StatusNotFound, ""},
+		{"GET", "/test/Simple", ``, http.StatusMethodNotAllowed, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+		req.URL.Path = "/" + ep + test.path
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%s %s: got status %d, want %d (%s)", test.method, test.path, got, want, w.Body)
+			continue
+		}
+		if test.response == "" {
+			continue
+		}
+		if got, want := w.Body.String(), test.response; got != want {
+			t.Errorf("%s %s: got %s, want %s", test.method, test.path, got, want)
+		}
+	}
+}
+
+func TestGatewayRejects(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	h := &gateway.Handler{
+		Ctx:         ctx,
+		Names:       []string{"allowed"},
+		MaxBodySize: 16,
+	}
+	tests := []struct {
+		path, contentType, origin, body string
+		status                          int
+	}{
+		// Browsers send text/plain and form posts to other sites without a
+		// preflight request.
+		{"/allowed/Simple", "text/plain", "", `{}`, http.StatusUnsupportedMediaType},
+		{"/allowed/Simple", "application/x-www-form-urlencoded", "", `{}`, http.StatusUnsupportedMediaType},
+		{"/allowed/Simple", "", "", `{}`, http.StatusUnsupportedMediaType},
+		{"/allowed/Simple", "application/json", "http://evil.example.com", `{}`, http.StatusForbidden},
+		{"/other/Simple", "application/json", "", `{}`, http.StatusForbidden},
+		{"/allowedx/Simple", "application/json", "", `{}`, http.StatusForbidden},
+		{"/allowed/Simple", "application/json", "", `{"a": "01234567890123456789"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%s %q %q: got status %d, want %d (%s)", test.path, test.contentType, test.origin, got, want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package clientproxy forwards the calls that mojo apps make over message
+// pipes to Vanadium services. It is the part of the v23clientproxy app that
+// does not depend on the mojo shell, so that it can also run in tests with
+// in-process message pipes.
+package clientproxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"mojo/public/go/bindings"
+	"mojo/public/go/system"
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/context"
+	"v.io/v23/vom"
+	"v.io/v23/vtrace"
+	"v.io/x/mojo/proxy/capture"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+	"v.io/x/mojo/proxy/util"
+	"v.io/x/mojo/transcoder"
+)
+
+// Proxy forwards the messages that mojo apps send over pipes to Vanadium.
+type Proxy struct {
+	Sessions *session.Registry
+	Metrics  *metrics.Recorder
+	Traces   *TraceLog
+	// MaxPipeConcurrency bounds the number of requests from a single pipe
+	// that may be in flight over Vanadium at once.
+	MaxPipeConcurrency int
+	// LogArgs lists the mojom interfaces whose call arguments are logged,
+	// in the format accepted by util.MatchInterface.
+	LogArgs string
+	// Capture, if not nil, records every call that the proxy forwards.
+	Capture *capture.Writer
+}
+
+// pipe describes the Vanadium service that the messages on one pipe are
+// forwarded to. ctx is canceled when the pipe closes.
+type pipe struct {
+	proxy       *Proxy
+	ctx         *context.T
+	v23Name     string
+	ifaceSig    mojom_types.MojomInterface
+	desc        map[string]mojom_types.UserDefinedType
+	defaults    transcoder.Defaults
+	serviceName string
+	opts        Options
+}
+
+// Setup starts forwarding the messages that arrive on handle to v23Name,
+// whose mojom interface is ifaceSig. Each pipe is served independently, so
+// Setup may be called any number of times.
+func (p *Proxy) Setup(ctx *context.T, v23Name string, ifaceSig mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType, serviceName string, handle system.MessagePipeHandle, opts Options) error {
+	log, span := p.Traces.Start(ctx, v23Name, "v23clientproxy.SetupClientProxy")
+	defer span.Finish()
+	log.VI(1).Infof("SetupClientProxy(%s, %s)", v23Name, serviceName)
+	log.VI(2).Infof("SetupClientProxy(%s): interface %v, types %v", v23Name, ifaceSig, desc)
+	defaults, err := transcoder.MojomDefaults(desc)
+	if err != nil {
+		log.Errorf("rejecting client proxy for %s: %v", v23Name, err)
+		handle.Close()
+		return err
+	}
+	pipeCtx, cancel := context.WithCancel(ctx)
+	info := &pipe{
+		proxy:       p,
+		ctx:         pipeCtx,
+		v23Name:     v23Name,
+		ifaceSig:    ifaceSig,
+		desc:        desc,
+		defaults:    defaults,
+		serviceName: serviceName,
+		opts:        opts,
+	}
+
+	connector := bindings.NewConnector(handle, bindings.GetAsyncWaiter())
+	receiver := newMessageReceiver(info, connector, cancel, p.MaxPipeConcurrency)
+	stub := bindings.NewStub(connector, receiver)
+	s, err := p.Sessions.Add(session.Info{
+		Kind:      "client",
+		Name:      v23Name,
+		Interface: serviceName,
+	}, receiver.close)
+	if err != nil {
+		log.Errorf("rejecting client proxy for %s: %v", v23Name, err)
+		receiver.close()
+		return err
+	}
+
+	go func() {
+		// Read generic calls in a loop until the app closes its end.
+		defer s.Close()
+		for {
+			if err := stub.ServeRequest(); err != nil {
+				connectionError, ok := err.(*bindings.ConnectionError)
+				if !ok || !connectionError.Closed() {
+					log.Errorf("%v", err)
+				}
+				break
+			}
+		}
+	}()
+	return nil
+}
+
+type messageReceiver struct {
+	pipe      *pipe
+	ctx       *context.T
+	connector *bindings.Connector
+
+	// closeOnce guards cancel and the closing of the connector, which must
+	// only be closed once.
+	closeOnce sync.Once
+	cancel    func()
+
+	// slots holds one token per request that may be in flight.
+	slots chan struct{}
+
+	// writeMu serializes writes of responses to the connector.
+	writeMu sync.Mutex
+}
+
+func newMessageReceiver(pipe *pipe, connector *bindings.Connector, cancel func(), maxConcurrency int) *messageReceiver {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	return &messageReceiver{
+		pipe:      pipe,
+		ctx:       pipe.ctx,
+		connector: connector,
+		cancel:    cancel,
+		slots:     make(chan struct{}, maxConcurrency),
+	}
+}
+
+// close closes the pipe and cancels the Vanadium calls in flight for it, so
+// that they give up their slots. It may be called any number of times.
+func (s *messageReceiver) close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.connector.Close()
+	})
+}
+
+func (s *messageReceiver) Accept(message *bindings.Message) (err error) {
+	if _, ok := s.pipe.ifaceSig.Methods[message.Header.Type]; !ok {
+		return fmt.Errorf("Method had index %d, but interface only has %d methods",
+			message.Header.Type, len(s.pipe.ifaceSig.Methods))
+	}
+
+	methodSig := s.pipe.ifaceSig.Methods[message.Header.Type]
+	methodName := *methodSig.DeclData.ShortName
+	// Should we perform validation of flags like generated methods?
+	// Does this handle 0-arg methods?
+
+	messageBytes := message.Payload
+
+	if methodSig.ResponseParams == nil {
+		// This may be complicated to support because of a race on the server side.
+		// When we send the no-response message through mojo we don't know when it has been received
+		// and we can close the pipe (one is opened per incoming message).
+
+		// Note that because the client expects no response, it will not receive the following
+		// error.
+		return fmt.Errorf("this error not received")
+	}
+
+	// Wait for a free slot before dispatching. This blocks the serve loop, so
+	// a pipe with MaxPipeConcurrency requests outstanding stops reading until
+	// one of them completes.
+	s.slots <- struct{}{}
+	header := message.Header
+	go func() {
+		defer func() { <-s.slots }()
+		// The method was resolved from the ordinal above, so the metrics are
+		// bounded by the methods of the interface.
+		mc := s.pipe.proxy.Metrics.Method(metrics.Key{
+			Name:      s.pipe.v23Name,
+			Interface: s.pipe.serviceName,
+			Method:    methodName,
+		}).Start()
+		ctx, span := s.pipe.proxy.Traces.Start(s.ctx, s.pipe.v23Name, "v23clientproxy:"+s.pipe.v23Name+"."+methodName)
+		defer span.Finish()
+		var rec *capture.Record
+		if s.pipe.proxy.Capture != nil {
+			rec = &capture.Record{
+				Time:      time.Now(),
+				Proxy:     "client",
+				Name:      s.pipe.v23Name,
+				Interface: s.pipe.serviceName,
+				Method:    methodName,
+				Ordinal:   header.Type,
+				Request:   messageBytes,
+			}
+		}
+		response, err := s.call(ctx, mc, rec, s.pipe.v23Name, methodName, isIdempotent(methodSig.DeclData), messageBytes, methodSig.Parameters, methodSig.ResponseParams)
+		if rec != nil {
+			rec.Response = response
+			rec.SetError(err)
+			if err := s.pipe.proxy.Capture.Write(*rec); err != nil {
+				s.ctx.Errorf("capturing %s.%s failed: %v", s.pipe.v23Name, methodName, err)
+			}
+		}
+		if err == nil {
+			err = s.writeResponse(header, response)
+		}
+		mc.AddBytes(len(messageBytes), len(response))
+		mc.Finish(err)
+		if err != nil {
+			// Mojo has no way to report a failure for a single request, so as
+			// before the pipe is closed and the app sees a connection error.
+			// The other requests in flight on the pipe are canceled.
+			if s.ctx.Err() == nil {
+				s.ctx.Errorf("%s.%s failed: %v", s.pipe.v23Name, methodName, err)
+			}
+			s.close()
+		}
+	}()
+	return nil
+}
+
+// writeResponse sends the mojom-encoded response for the request with the
+// given header back over the pipe.
+func (s *messageReceiver) writeResponse(requestHeader bindings.MessageHeader, response []byte) error {
+	// TODO(alexfandrianto): This assumes that bindings.Encoder has the method
+	// WriteRawBytes. We will need to add this to Mojo ourselves.
+	// func (e *Encoder) WriteRawBytes(data []byte) {
+	// 	first := e.end
+	// 	e.claimData(align(len(data), defaultAlignment))
+	// 	copy(e.buf[first:], data)
+	// }
+	//
+	// See: https://codereview.chromium.org/1416433002/
+
+	responseHeader := bindings.MessageHeader{
+		Type:      requestHeader.Type,
+		Flags:     bindings.MessageIsResponseFlag,
+		RequestId: requestHeader.RequestId,
+	}
+	// responseMessage, err := bindings.EncodeMessage(responseHeader, byteCopyingPayload(response))
+	// if err != nil {
+	// 	return err
+	// }
+	// return s.connector.WriteMessage(responseMessage)
+
+	// TODO(alexfandrianto): Replace this block with the above.
+	encoder := bindings.NewEncoder()
+	if err := responseHeader.Encode(encoder); err != nil {
+		return err
+	}
+	if bytes, handles, err := encoder.Data(); err != nil {
+		return err
+	} else {
+		// response is our payload; append to the end of our slice.
+		bytes = append(bytes, response...)
+
+		// This is analogous to bindings.newMessage
+		responseMessage := &bindings.Message{
+			Header:  responseHeader,
+			Bytes:   bytes,
+			Handles: handles,
+			Payload: response,
+		}
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+		return s.connector.WriteMessage(responseMessage)
+	}
+}
+
+// call forwards a request to the v23 name and returns the mojom-encoded
+// response. idempotent tells whether the method may run more than once. The
+// time spent transcoding and in the Vanadium RPC is added to mc,
+// and the VOM-encoded arguments and results to rec, if it is not nil.
+func (s *messageReceiver) call(ctx *context.T, mc *metrics.Call, rec *capture.Record, name, method string, idempotent bool, value []byte, inParamsType mojom_types.MojomStruct, outParamsType *mojom_types.MojomStruct) ([]byte, error) {
+	ctx.VI(1).Infof("%s.%s", name, method)
+	ctx.VI(2).Infof("%s.%s: %v => %v", name, method, inParamsType, outParamsType)
+	// span and start track the transcoding phase that is in progress, if any.
+	start := time.Now()
+	_, span := vtrace.WithNewSpan(ctx, "transcode request")
+	defer func() {
+		if span != nil {
+			span.Finish()
+			mc.Add(metrics.Transcode, time.Since(start))
+		}
+	}()
+	inVType, err := transcoder.MojomStructToVDLType(inParamsType, s.pipe.desc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the vom.RawBytes from the mojom bytes and mojom type.
+	target := util.StructSplitTarget()
+	if err := transcoder.FromMojo(target, value, inVType, s.pipe.defaults); err != nil {
+		return nil, fmt.Errorf("transcoder.FromMojo failed: %v", err)
+	}
+
+	// inVdlValue is a struct, but we need to send []interface.
+	inargs := target.Fields()
+	if util.MatchInterface(s.pipe.proxy.LogArgs, s.pipe.serviceName) {
+		util.LogArgs(ctx, "call", name, method, inParamsType, s.pipe.desc, inargs)
+	}
+	if rec != nil {
+		rec.SetArgs(inargs)
+	}
+	inargsIfc := make([]interface{}, len(inargs))
+	for i := range inargs {
+		inargsIfc[i] = inargs[i]
+	}
+
+	// We know that the v23serverproxy will give us back a bunch of
+	// data in []interface{}. so we'll want to decode them into *vom.RawBytes.
+	var numParams int
+	if outParamsType != nil {
+		numParams = len(outParamsType.Fields)
+	}
+	outargs := make([]*vom.RawBytes, numParams)
+	outptrs := make([]interface{}, len(outargs))
+	for i := range outargs {
+		outptrs[i] = &outargs[i]
+	}
+
+	// Now, run the call. Unless the app asked otherwise, any server is
+	// authorized.
+	span.Finish()
+	span = nil
+	mc.Add(metrics.Transcode, time.Since(start))
+	start = time.Now()
+	err = s.pipe.opts.call(ctx, name, method, idempotent, inargsIfc, outptrs)
+	mc.Add(metrics.Network, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	start = time.Now()
+	_, span = vtrace.WithNewSpan(ctx, "transcode response")
+
+	if outParamsType == nil {
+		return nil, nil
+	}
+
+	if rec != nil {
+		rec.SetResults(outargs)
+	}
+	if util.MatchInterface(s.pipe.proxy.LogArgs, s.pipe.serviceName) {
+		util.LogArgs(ctx, "return", name, method, *outParamsType, s.pipe.desc, outargs)
+	}
+	outVType, err := transcoder.MojomStructToVDLType(*outParamsType, s.pipe.desc)
+	if err != nil {
+		return nil, err
+	}
+
+	toMojoTarget := transcoder.ToMojomTarget(s.pipe.defaults)
+	if err := util.JoinRawBytesAsStruct(toMojoTarget, outVType, outargs); err != nil {
+		return nil, err
+	}
+	return toMojoTarget.Bytes(), nil
+}
@@ -0,0 +1,361 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mojo/public/go/application"
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/service_describer"
+
+	"mojom/tests/end_to_end_test"
+	"mojom/v23clientproxy"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/security"
+	"v.io/x/mojo/internal/clientproxy"
+	"v.io/x/mojo/internal/serverproxy"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+	"v.io/x/mojo/proxy/util"
+	"v.io/x/mojo/tests/expected"
+	"v.io/x/mojo/tests/impl"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+const testURL = "https://mojo.v.io/test_server.mojo"
+
+// slowImpl is a V23ProxyTest implementation whose FetchMsgFromNoOutArgsPut
+// blocks until release is closed. All the calls share it.
+type slowImpl struct {
+	*impl.V23ProxyTestImpl
+	started chan struct{}
+	release chan struct{}
+}
+
+func (i *slowImpl) FetchMsgFromNoOutArgsPut() (string, error) {
+	i.started <- struct{}{}
+	select {
+	case <-i.release:
+		return "released", nil
+	case <-time.After(5 * time.Second):
+		return "", fmt.Errorf("not released")
+	}
+}
+
+func (i *slowImpl) Create(r end_to_end_test.V23ProxyTest_Request) {
+	impl.Serve(r, i)
+}
+
+// startServer serves the V23ProxyTest service of an in-process app as the
+// export "test" and returns its name.
+func startServer(t *testing.T, ctx *context.T, prefix string, gate *serverproxy.Gate) string {
+	return startServerWith(t, ctx, prefix, gate, impl.Factory{})
+}
+
+// startServerWith is like startServer, with the implementations created by f.
+func startServerWith(t *testing.T, ctx *context.T, prefix string, gate *serverproxy.Gate, f end_to_end_test.V23ProxyTest_Factory) string {
+	factory := &end_to_end_test.V23ProxyTest_ServiceFactory{f}
+	return startDispatcher(t, ctx, &serverproxy.Dispatcher{
+		Apps:     serverproxy.Factories{testURL: {factory}},
+		Sessions: session.NewRegistry(0),
+		Metrics:  metrics.NewRecorder(prefix+"/server", metrics.Transcode, metrics.App),
+		Gate:     gate,
+		Auth:     security.AllowEveryone(),
+	})
+}
+
+// startDispatcher serves the V23ProxyTest service at testURL as the export
+// "test" of d and returns its name.
+func startDispatcher(t *testing.T, ctx *context.T, d *serverproxy.Dispatcher) string {
+	registry, err := exports.NewRegistry(exports.Export{
+		Name:    "test",
+		Address: util.Address{URL: testURL, Interface: (&end_to_end_test.V23ProxyTest_ServiceFactory{}).Name()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Exports = registry
+	_, server, err := v23.WithNewDispatchingServer(ctx, "", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return naming.Join(server.Status().Endpoints[0].Name(), "test")
+}
+
+// connect sets up a client proxy for the V23ProxyTest service at name.
+func connect(t *testing.T, ctx *context.T, proxy *clientproxy.Proxy, name string, opts clientproxy.Options) *end_to_end_test.V23ProxyTest_Proxy {
+	r, p := end_to_end_test.CreateMessagePipeForV23ProxyTest()
+	sd := r.ServiceDescription()
+	iface, err := sd.GetTopLevelInterface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := sd.GetAllTypeDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.Setup(ctx, name, iface, *desc, r.Name(), r.PassMessagePipe(), opts); err != nil {
+		t.Fatal(err)
+	}
+	return end_to_end_test.NewV23ProxyTestProxy(p, bindings.GetAsyncWaiter())
+}
+
+func newProxy(prefix string) *clientproxy.Proxy {
+	return &clientproxy.Proxy{
+		Sessions:           session.NewRegistry(0),
+		Metrics:            metrics.NewRecorder(prefix+"/client", metrics.Transcode, metrics.Network),
+		Traces:             clientproxy.NewTraceLog(),
+		MaxPipeConcurrency: 4,
+	}
+}
+
+// startSlowCall starts a call to FetchMsgFromNoOutArgsPut through client and
+// returns once the app has received it. The result of the call is sent on
+// the returned channel.
+func startSlowCall(t *testing.T, client *end_to_end_test.V23ProxyTest_Proxy, slow *slowImpl) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		msg, err := client.FetchMsgFromNoOutArgsPut()
+		if err == nil && msg != "released" {
+			err = fmt.Errorf("got %q, want %q", msg, "released")
+		}
+		done <- err
+	}()
+	select {
+	case <-slow.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slow call did not reach the app")
+	}
+	return done
+}
+
+func TestEndToEnd(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	name := startServer(t, ctx, "end-to-end", &serverproxy.Gate{})
+	proxy := newProxy("end-to-end")
+	client := connect(t, ctx, proxy, name, clientproxy.Options{})
+	defer client.Close_Proxy()
+
+	value, err := client.Simple(expected.SimpleRequestA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := value, expected.SimpleResponseValue; got != want {
+		t.Errorf("Simple: got %q, want %q", got, want)
+	}
+
+	x, y, err := client.MultiArgs(expected.MultiArgsRequestA, expected.MultiArgsRequestB, expected.MultiArgsRequestC, expected.MultiArgsRequestD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(x, expected.MultiArgsResponseX) || y != expected.MultiArgsResponseY {
+		t.Errorf("MultiArgs: got (%v, %q), want (%v, %q)", x, y, expected.MultiArgsResponseX, expected.MultiArgsResponseY)
+	}
+
+	var calls int64
+	for _, snap := range proxy.Metrics.Snapshot() {
+		calls += snap.Calls
+	}
+	if got, want := calls, int64(2); got != want {
+		t.Errorf("got %d calls recorded, want %d", got, want)
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	slow := &slowImpl{impl.NewV23ProxyTestImpl(), make(chan struct{}, 1), make(chan struct{})}
+	name := startServerWith(t, ctx, "concurrent", &serverproxy.Gate{}, slow)
+	client := connect(t, ctx, newProxy("concurrent"), name, clientproxy.Options{})
+	defer client.Close_Proxy()
+
+	slowDone := startSlowCall(t, client, slow)
+	// A fast call on the same pipe completes while the slow one is in flight.
+	if _, err := client.Simple(expected.SimpleRequestA); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-slowDone:
+		t.Fatalf("the slow call completed before it was released: %v", err)
+	default:
+	}
+	close(slow.release)
+	if err := <-slowDone; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipeConcurrencyLimit(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	slow := &slowImpl{impl.NewV23ProxyTestImpl(), make(chan struct{}, 1), make(chan struct{})}
+	name := startServerWith(t, ctx, "limit", &serverproxy.Gate{}, slow)
+	proxy := newProxy("limit")
+	proxy.MaxPipeConcurrency = 1
+	client := connect(t, ctx, proxy, name, clientproxy.Options{})
+	defer client.Close_Proxy()
+
+	slowDone := startSlowCall(t, client, slow)
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := client.Simple(expected.SimpleRequestA)
+		fastDone <- err
+	}()
+	// The only slot is taken, so the fast call waits for the slow one.
+	select {
+	case err := <-fastDone:
+		t.Fatalf("a second call completed beyond the limit of 1: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(slow.release)
+	if err := <-slowDone; err != nil {
+		t.Error(err)
+	}
+	if err := <-fastDone; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClosedPipeCancelsCalls(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	slow := &slowImpl{impl.NewV23ProxyTestImpl(), make(chan struct{}, 1), make(chan struct{})}
+	defer close(slow.release)
+	name := startServerWith(t, ctx, "closed", &serverproxy.Gate{}, slow)
+	proxy := newProxy("closed")
+	client := connect(t, ctx, proxy, name, clientproxy.Options{})
+
+	startSlowCall(t, client, slow)
+	client.Close_Proxy()
+	// The Vanadium call fails as soon as the pipe is closed, long before the
+	// app gives up on being released.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var errors int64
+		for _, snap := range proxy.Metrics.Snapshot() {
+			errors += snap.Errors
+		}
+		if errors > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the call in flight was not canceled when the pipe closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := proxy.Sessions.Len(); got != 0 {
+		t.Errorf("got %d sessions after the pipe closed, want 0", got)
+	}
+}
+
+func TestDrainedServer(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	gate := &serverproxy.Gate{}
+	name := startServer(t, ctx, "drained", gate)
+	gate.Drain()
+	client := connect(t, ctx, newProxy("drained"), name, clientproxy.Options{})
+	defer client.Close_Proxy()
+
+	// The proxy closes the pipe when a call fails.
+	if _, err := client.Simple(expected.SimpleRequestA); err == nil {
+		t.Errorf("Simple succeeded on a drained server")
+	}
+}
+
+// describeCounter counts the requests to describe the apps of Apps.
+type describeCounter struct {
+	serverproxy.Apps
+	n int32
+}
+
+func (c *describeCounter) ConnectToService(url string, r application.ServiceRequest) {
+	if _, ok := r.(*service_describer.ServiceDescriber_Request); ok {
+		atomic.AddInt32(&c.n, 1)
+	}
+	c.Apps.ConnectToService(url, r)
+}
+
+func TestDescribeAfterAuthorization(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	factory := &end_to_end_test.V23ProxyTest_ServiceFactory{impl.Factory{}}
+	deny := security.AuthorizerFunc(func(*context.T, security.Call) error {
+		return fmt.Errorf("denied")
+	})
+	for _, c := range []struct {
+		auth      security.Authorizer
+		calls     int
+		describes int32
+	}{
+		// Callers that are not authorized cannot make the proxy connect
+		// to the app.
+		{deny, 1, 0},
+		// The app is described once for all the calls.
+		{security.AllowEveryone(), 3, 1},
+	} {
+		apps := &describeCounter{Apps: serverproxy.Factories{testURL: {factory}}}
+		name := startDispatcher(t, ctx, &serverproxy.Dispatcher{
+			Apps:     apps,
+			Sessions: session.NewRegistry(0),
+			Metrics:  metrics.NewRecorder(fmt.Sprintf("describe-%d/server", c.calls), metrics.Transcode, metrics.App),
+			Gate:     &serverproxy.Gate{},
+			Auth:     c.auth,
+		})
+		for i := 0; i < c.calls; i++ {
+			// The proxy closes the pipe when a call fails, so each call
+			// has its own.
+			client := connect(t, ctx, newProxy(fmt.Sprintf("describe-%d-%d", c.calls, i)), name, clientproxy.Options{})
+			_, err := client.Simple(expected.SimpleRequestA)
+			client.Close_Proxy()
+			if got, want := err == nil, c.describes > 0; got != want {
+				t.Errorf("Simple: got error %v, want success %v", err, want)
+			}
+		}
+		if got := atomic.LoadInt32(&apps.n); got != c.describes {
+			t.Errorf("the app was described %d times, want %d", got, c.describes)
+		}
+	}
+}
+
+func TestServerBlessings(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	name := startServer(t, ctx, "blessings", &serverproxy.Gate{})
+	blessings := []string{"nobody"}
+	opts, err := clientproxy.ParseOptions(v23clientproxy.ClientProxyOptions{ServerBlessings: &blessings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := connect(t, ctx, newProxy("blessings"), name, opts)
+	defer client.Close_Proxy()
+
+	if _, err := client.Simple(expected.SimpleRequestA); err == nil {
+		t.Errorf("Simple succeeded on a server without the expected blessings")
+	}
+
+	invalid := []string{"a/"}
+	if _, err := clientproxy.ParseOptions(v23clientproxy.ClientProxyOptions{ServerBlessings: &invalid}); err == nil {
+		t.Errorf("ParseOptions accepted the invalid pattern %q", invalid[0])
+	}
+}
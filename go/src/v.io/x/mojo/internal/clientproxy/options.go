@@ -0,0 +1,110 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy
+
+import (
+	"fmt"
+	"time"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"mojom/v23clientproxy"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/security/access"
+	"v.io/v23/verror"
+	"v.io/x/mojo/transcoder"
+)
+
+// Options holds the per-pipe configuration of the Vanadium calls made for a
+// mojo app. The zero value authorizes any server, has no timeout and does
+// not retry, as SetupClientProxy always did.
+type Options struct {
+	authorizer security.Authorizer
+	timeout    time.Duration
+	attempts   int
+	backoff    time.Duration
+}
+
+// ParseOptions converts the options sent by a mojo app.
+func ParseOptions(o v23clientproxy.ClientProxyOptions) (Options, error) {
+	opts := Options{
+		timeout:  time.Duration(o.CallTimeoutMs) * time.Millisecond,
+		attempts: int(o.MaxAttempts),
+		backoff:  time.Duration(o.RetryBackoffMs) * time.Millisecond,
+	}
+	if o.ServerBlessings != nil {
+		var acl access.AccessList
+		for _, p := range *o.ServerBlessings {
+			pattern := security.BlessingPattern(p)
+			if !pattern.IsValid() {
+				return Options{}, fmt.Errorf("invalid server blessing pattern %q", p)
+			}
+			acl.In = append(acl.In, pattern)
+		}
+		opts.authorizer = acl
+	}
+	return opts, nil
+}
+
+// serverAuthorizer returns the option that authorizes the servers called.
+func (o Options) serverAuthorizer() options.ServerAuthorizer {
+	if o.authorizer == nil {
+		return options.ServerAuthorizer{security.AllowEveryone()}
+	}
+	return options.ServerAuthorizer{o.authorizer}
+}
+
+// isIdempotent returns true if decl, the declaration of a mojom method, has
+// tags that mark it idempotent, see transcoder.Idempotent. The server proxy
+// gives the method the same tags.
+func isIdempotent(decl *mojom_types.DeclarationData) bool {
+	tags, err := transcoder.MojomMethodTags(decl)
+	return err == nil && transcoder.Idempotent(tags)
+}
+
+// v23Call makes a Vanadium call. Tests replace it.
+var v23Call = func(ctx *context.T, name, method string, inargs, outptrs []interface{}, opts ...rpc.CallOpt) error {
+	return v23.GetClient(ctx).Call(ctx, name, method, inargs, outptrs, opts...)
+}
+
+// call makes a Vanadium call to name.method, retrying calls that never
+// reached the server, and, if the method is idempotent, calls that failed
+// with an error that allows a retry. Other calls are not retried, since the
+// server may already have run the method.
+func (o Options) call(ctx *context.T, name, method string, idempotent bool, inargs, outptrs []interface{}) error {
+	backoff := o.backoff
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := ctx, func() {}
+		if o.timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, o.timeout)
+		}
+		err := v23Call(callCtx, name, method, inargs, outptrs, o.serverAuthorizer())
+		cancel()
+		if err == nil || attempt >= o.attempts || !retryable(err, idempotent) {
+			return err
+		}
+		ctx.VI(1).Infof("%s.%s: attempt %d failed, retrying in %v: %v", name, method, attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// retryable returns true if a call that failed with err may be retried.
+func retryable(err error, idempotent bool) bool {
+	if verror.ErrorID(err) == verror.ErrNoServers.ID {
+		// The call was not sent to any server.
+		return true
+	}
+	return idempotent && verror.Action(err).RetryAction() != verror.NoRetry
+}
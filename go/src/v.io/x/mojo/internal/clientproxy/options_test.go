@@ -0,0 +1,114 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy
+
+import (
+	"testing"
+	"time"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/verror"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+var errFlaky = verror.Register("v.io/x/mojo/internal/clientproxy.errFlaky", verror.RetryBackoff, "{1:}{2:} flaky")
+
+// fakeCalls replaces v23Call with a function that returns the errors in turn,
+// then nil, and returns the number of calls made and a function that
+// restores v23Call.
+func fakeCalls(errs ...verror.IDAction) (*int, func()) {
+	calls := 0
+	saved := v23Call
+	v23Call = func(ctx *context.T, name, method string, inargs, outptrs []interface{}, opts ...rpc.CallOpt) error {
+		calls++
+		if calls <= len(errs) {
+			return verror.New(errs[calls-1], ctx)
+		}
+		return nil
+	}
+	return &calls, func() { v23Call = saved }
+}
+
+func TestRetry(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	tests := []struct {
+		errs       []verror.IDAction
+		idempotent bool
+		attempts   int
+		calls      int
+		ok         bool
+	}{
+		// Calls that never reached a server are retried.
+		{[]verror.IDAction{verror.ErrNoServers, verror.ErrNoServers}, false, 3, 3, true},
+		{[]verror.IDAction{verror.ErrNoServers, verror.ErrNoServers}, false, 2, 2, false},
+		// Other failures are only retried for idempotent methods.
+		{[]verror.IDAction{errFlaky}, false, 3, 1, false},
+		{[]verror.IDAction{errFlaky}, true, 3, 2, true},
+		// Errors that do not allow a retry are never retried.
+		{[]verror.IDAction{verror.ErrNoAccess}, true, 3, 1, false},
+		// The zero value makes a single attempt.
+		{[]verror.IDAction{verror.ErrNoServers}, false, 0, 1, false},
+	}
+	for i, test := range tests {
+		calls, restore := fakeCalls(test.errs...)
+		opts := Options{attempts: test.attempts, backoff: time.Millisecond}
+		err := opts.call(ctx, "server", "Method", test.idempotent, nil, nil)
+		restore()
+		if (err == nil) != test.ok {
+			t.Errorf("%d: got error %v, want ok %v", i, err, test.ok)
+		}
+		if *calls != test.calls {
+			t.Errorf("%d: got %d calls, want %d", i, *calls, test.calls)
+		}
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	saved := v23Call
+	defer func() { v23Call = saved }()
+	v23Call = func(ctx *context.T, name, method string, inargs, outptrs []interface{}, opts ...rpc.CallOpt) error {
+		select {
+		case <-ctx.Done():
+			return verror.New(verror.ErrTimeout, ctx)
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+	opts := Options{timeout: 10 * time.Millisecond}
+	start := time.Now()
+	if err := opts.call(ctx, "server", "Method", false, nil, nil); verror.ErrorID(err) != verror.ErrTimeout.ID {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the call took %v despite a timeout of %v", elapsed, opts.timeout)
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		attrs []mojom_types.Attribute
+		want  bool
+	}{
+		{nil, false},
+		{[]mojom_types.Attribute{{Key: "Idempotent"}}, true},
+		{[]mojom_types.Attribute{{Key: "Idempotent", Value: &mojom_types.LiteralValueBoolValue{false}}}, false},
+		{[]mojom_types.Attribute{{Key: "Access", Value: &mojom_types.LiteralValueStringValue{"Read"}}}, false},
+	}
+	for _, test := range tests {
+		attrs := test.attrs
+		if got := isIdempotent(&mojom_types.DeclarationData{Attributes: &attrs}); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.attrs, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy
+
+import (
+	"bytes"
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/uniqueid"
+	"v.io/v23/vtrace"
+)
+
+// maxTracesPerName bounds the number of trace ids remembered per v23 name.
+const maxTracesPerName = 16
+
+// TraceLog remembers which v23 names mojo apps asked to trace, and the ids of
+// the traces collected for them.
+type TraceLog struct {
+	mu      sync.Mutex
+	enabled map[string]bool
+	ids     map[string][]uniqueid.Id
+}
+
+func NewTraceLog() *TraceLog {
+	return &TraceLog{
+		enabled: map[string]bool{},
+		ids:     map[string][]uniqueid.Id{},
+	}
+}
+
+// SetEnabled turns the collection of traces for name on or off.
+func (l *TraceLog) SetEnabled(name string, enable bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if enable {
+		l.enabled[name] = true
+	} else {
+		delete(l.enabled, name)
+	}
+}
+
+// Start begins a new trace for a call to name. The trace is collected if a
+// mojo app asked for calls to name to be traced.
+func (l *TraceLog) Start(ctx *context.T, name, spanName string) (*context.T, vtrace.Span) {
+	ctx, _ = vtrace.WithNewTrace(ctx)
+	ctx, span := vtrace.WithNewSpan(ctx, spanName)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.enabled[name] {
+		vtrace.ForceCollect(ctx)
+		ids := append(l.ids[name], span.Trace())
+		if len(ids) > maxTracesPerName {
+			ids = ids[len(ids)-maxTracesPerName:]
+		}
+		l.ids[name] = ids
+	}
+	return ctx, span
+}
+
+// Formatted returns the collected traces for name, oldest first.
+func (l *TraceLog) Formatted(ctx *context.T, name string) []string {
+	l.mu.Lock()
+	ids := append([]uniqueid.Id(nil), l.ids[name]...)
+	l.mu.Unlock()
+
+	traces := make([]string, 0, len(ids))
+	store := vtrace.GetStore(ctx)
+	for _, id := range ids {
+		record := store.TraceRecord(id)
+		if record == nil || len(record.Spans) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		vtrace.FormatTrace(&buf, record, nil)
+		traces = append(traces, buf.String())
+	}
+	return traces
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy
+
+import (
+	"fmt"
+
+	"mojo/public/go/system"
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc/reserved"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/transcoder"
+)
+
+// DescribeVanadiumService fetches the signature of the Vanadium service at
+// name and converts it to a mojom interface. The methods of all the
+// interfaces that the service implements share a namespace, so they are
+// merged into a single mojom interface, ordered by name.
+func DescribeVanadiumService(ctx *context.T, name string, opts Options) (mojom_types.MojomInterface, map[string]mojom_types.UserDefinedType, error) {
+	sigs, err := reserved.Signature(ctx, name, opts.serverAuthorizer())
+	if err != nil {
+		return mojom_types.MojomInterface{}, nil, err
+	}
+	if len(sigs) == 0 {
+		return mojom_types.MojomInterface{}, nil, fmt.Errorf("%s has no methods", name)
+	}
+	merged := signature.Interface{
+		Name:    sigs[0].Name,
+		PkgPath: sigs[0].PkgPath,
+		Doc:     sigs[0].Doc,
+	}
+	seen := map[string]bool{}
+	for _, sig := range sigs {
+		for _, m := range sig.Methods {
+			if !seen[m.Name] {
+				seen[m.Name] = true
+				merged.Methods = append(merged.Methods, m)
+			}
+		}
+	}
+	transcoder.SortMethods(merged.Methods)
+	return transcoder.VDLInterfaceToMojomInterface(merged)
+}
+
+// SetupVanadium starts forwarding the messages that arrive on handle to the
+// Vanadium service at v23Name. Unlike Setup, the mojom interface is not
+// provided by the app but converted from the signature of the service, as
+// returned by DescribeVanadiumService.
+func (p *Proxy) SetupVanadium(ctx *context.T, v23Name string, handle system.MessagePipeHandle, opts Options) error {
+	iface, desc, err := DescribeVanadiumService(ctx, v23Name, opts)
+	if err != nil {
+		handle.Close()
+		return err
+	}
+	return p.Setup(ctx, v23Name, iface, desc, *iface.DeclData.FullIdentifier, handle, opts)
+}
+
+// Description serves the mojom description of a Vanadium service through
+// the ServiceDescription interface.
+type Description struct {
+	Interface mojom_types.MojomInterface
+	Types     map[string]mojom_types.UserDefinedType
+}
+
+func (d *Description) GetTopLevelInterface() (outMojomInterface mojom_types.MojomInterface, err error) {
+	return d.Interface, nil
+}
+
+func (d *Description) GetTypeDefinition(inTypeKey string) (outType mojom_types.UserDefinedType, err error) {
+	return d.Types[inTypeKey], nil
+}
+
+func (d *Description) GetAllTypeDefinitions() (outDefinitions *map[string]mojom_types.UserDefinedType, err error) {
+	return &d.Types, nil
+}
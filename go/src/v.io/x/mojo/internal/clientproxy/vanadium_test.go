@@ -0,0 +1,107 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientproxy_test
+
+import (
+	"testing"
+
+	"mojo/public/go/bindings"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/x/mojo/internal/clientproxy"
+	"v.io/x/mojo/transcoder"
+	"v.io/x/ref/test"
+)
+
+// adder is a Vanadium service that is not a mojo app.
+type adder struct{}
+
+func (adder) Add(_ *context.T, _ rpc.ServerCall, a, b int32) (int32, error) {
+	return a + b, nil
+}
+
+func (adder) Negate(_ *context.T, _ rpc.ServerCall, a int32) (int32, error) {
+	return -a, nil
+}
+
+func TestSetupVanadium(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	_, server, err := v23.WithNewServer(ctx, "", adder{}, security.AllowEveryone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := server.Status().Endpoints[0].Name()
+
+	iface, desc, err := clientproxy.DescribeVanadiumService(ctx, name, clientproxy.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ordinal uint32
+	found := false
+	for ord, m := range iface.Methods {
+		if *m.DeclData.ShortName == "Add" {
+			ordinal, found = ord, true
+		}
+	}
+	if !found || len(iface.Methods) != 2 {
+		t.Fatalf("got methods %v, want Add and Negate", iface.Methods)
+	}
+	if got, want := *iface.Methods[0].DeclData.ShortName, "Add"; got != want {
+		t.Errorf("got first method %q, want %q", got, want)
+	}
+
+	r, p := bindings.CreateMessagePipeForMojoInterface()
+	if err := newProxy("vanadium").SetupVanadium(ctx, name, r.PassMessagePipe(), clientproxy.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	router := bindings.NewRouter(p.PassMessagePipe(), bindings.GetAsyncWaiter())
+	defer router.Close()
+
+	// Call Add the way bindings generated from the converted interface would.
+	payload, err := transcoder.ToMojom(struct{ A, B int32 }{2, 3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := bindings.MessageHeader{
+		Type:      ordinal,
+		Flags:     bindings.MessageExpectsResponseFlag,
+		RequestId: 1,
+	}
+	encoder := bindings.NewEncoder()
+	if err := header.Encode(encoder); err != nil {
+		t.Fatal(err)
+	}
+	data, handles, err := encoder.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := <-router.AcceptWithResponse(&bindings.Message{
+		Header:  header,
+		Bytes:   append(data, payload...),
+		Handles: handles,
+		Payload: payload,
+	})
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	outType, err := transcoder.MojomStructToVDLType(*iface.Methods[ordinal].ResponseParams, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out *vdl.Value
+	if err := transcoder.ValueFromMojo(&out, result.Message.Payload, outType, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.StructField(0).Int(), int64(5); got != want {
+		t.Errorf("Add(2, 3): got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverproxy
+
+import (
+	"mojo/public/go/application"
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/service_describer"
+)
+
+// Apps connects the proxy to the mojo apps whose services it serves.
+type Apps interface {
+	// ConnectToService connects r to the service r.Name() of the app at url.
+	ConnectToService(url string, r application.ServiceRequest)
+}
+
+// AppContext returns the Apps that connects through the mojo shell of ctx.
+func AppContext(ctx application.Context) Apps {
+	return appContext{ctx}
+}
+
+type appContext struct {
+	ctx application.Context
+}
+
+func (a appContext) ConnectToService(url string, r application.ServiceRequest) {
+	a.ctx.ConnectToApplication(url).ConnectToService(r)
+}
+
+// Factories is the Apps made of the services of in-process mojo apps, keyed
+// by url. Like a mojo shell, it also answers ServiceDescriber requests for
+// them.
+type Factories map[string][]application.ServiceFactory
+
+func (f Factories) ConnectToService(url string, r application.ServiceRequest) {
+	factories := f[url]
+	if req, ok := r.(*service_describer.ServiceDescriber_Request); ok {
+		descriptions := describer{}
+		for _, factory := range factories {
+			descriptions[factory.Name()] = factory.ServiceDescription()
+		}
+		serve(service_describer.NewServiceDescriberStub(*req, descriptions, bindings.GetAsyncWaiter()))
+		return
+	}
+	for _, factory := range factories {
+		if factory.Name() == r.Name() {
+			factory.Create(r.PassMessagePipe())
+			return
+		}
+	}
+	// As in a mojo shell, a request for an unknown service is dropped.
+	r.PassMessagePipe().Close()
+}
+
+// describer implements ServiceDescriber for the services of one app.
+type describer map[string]service_describer.ServiceDescription
+
+func (d describer) DescribeService(interfaceName string, r service_describer.ServiceDescription_Request) error {
+	desc, ok := d[interfaceName]
+	if !ok {
+		r.PassMessagePipe().Close()
+		return nil
+	}
+	serve(service_describer.NewServiceDescriptionStub(r, desc, bindings.GetAsyncWaiter()))
+	return nil
+}
+
+// serve serves requests on stub until its pipe is closed.
+func serve(stub *bindings.Stub) {
+	go func() {
+		for stub.ServeRequest() == nil {
+		}
+	}()
+}
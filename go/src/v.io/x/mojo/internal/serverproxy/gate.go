@@ -0,0 +1,46 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverproxy
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrDraining is returned to callers that arrive after Drain.
+var ErrDraining = errors.New("v23serverproxy: not accepting new calls")
+
+// Gate tracks the calls in flight so that the proxy can stop accepting new
+// ones and wait for the rest to complete.
+type Gate struct {
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+}
+
+// Enter registers a new call. It returns false if the proxy is draining, in
+// which case the call must be rejected.
+func (g *Gate) Enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		return false
+	}
+	g.inflight.Add(1)
+	return true
+}
+
+// Exit marks a call registered by Enter as complete.
+func (g *Gate) Exit() {
+	g.inflight.Done()
+}
+
+// Drain rejects new calls and waits for the ones in flight.
+func (g *Gate) Drain() {
+	g.mu.Lock()
+	g.draining = true
+	g.mu.Unlock()
+	g.inflight.Wait()
+}
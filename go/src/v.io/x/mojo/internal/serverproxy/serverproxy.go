@@ -0,0 +1,563 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package serverproxy serves mojo apps over Vanadium. It is the part of the
+// v23serverproxy app that does not depend on the mojo shell, so that it can
+// also run in tests with in-process mojo services.
+package serverproxy
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"mojo/public/go/bindings"
+	"mojo/public/go/system"
+	"mojo/public/interfaces/bindings/mojom_types"
+	"mojo/public/interfaces/bindings/service_describer"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/v23/verror"
+	"v.io/v23/vom"
+	"v.io/v23/vtrace"
+	"v.io/x/mojo/proxy/capture"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+	"v.io/x/mojo/proxy/util"
+	"v.io/x/mojo/transcoder"
+)
+
+// As long as fakeService meets the Invoker interface, it is allowed to pass as
+// a universal v23 service.
+// See the function objectToInvoker in v.io/x/ref/runtime/internal/rpc/server.go
+type fakeService struct {
+	d      *Dispatcher
+	export exports.Export
+	router *bindings.Router
+	ids    bindings.Counter
+	desc   *description
+}
+
+// description is the description of an exported mojo interface. The mojo app
+// is described by the first call to the export that is authorized, and the
+// description is kept until the export is removed, see Dispatcher.Forget.
+type description struct {
+	addr     util.Address
+	mu       sync.Mutex
+	ok       bool
+	iface    mojom_types.MojomInterface
+	types    map[string]mojom_types.UserDefinedType
+	defaults transcoder.Defaults
+}
+
+// describe returns the description of the exported mojo interface, and
+// obtains it from the mojo app if it has not been yet. Failures are not
+// kept, so that the next call tries again.
+func (fs fakeService) describe() (mojom_types.MojomInterface, map[string]mojom_types.UserDefinedType, error) {
+	desc := fs.desc
+	desc.mu.Lock()
+	defer desc.mu.Unlock()
+	if !desc.ok {
+		iface, types, err := fs.callRemoteSignature(fs.export.URL, fs.export.Interface)
+		if err != nil {
+			return mojom_types.MojomInterface{}, nil, err
+		}
+		defaults, err := transcoder.MojomDefaults(types)
+		if err != nil {
+			return mojom_types.MojomInterface{}, nil, err
+		}
+		desc.iface, desc.types, desc.defaults, desc.ok = iface, types, defaults, true
+	}
+	return desc.iface, desc.types, nil
+}
+
+// cachedTags returns the tags of method if the exported mojo interface has
+// been described, and nil otherwise.
+func (fs fakeService) cachedTags(method string) ([]*vdl.Value, error) {
+	fs.desc.mu.Lock()
+	defer fs.desc.mu.Unlock()
+	if !fs.desc.ok {
+		return nil, nil
+	}
+	if _, mm, ok := findMethod(fs.desc.iface, method); ok {
+		return transcoder.MojomMethodTags(mm.DeclData)
+	}
+	return nil, nil
+}
+
+// Prepare is used by the Fake Service to prepare the placeholders for the
+// input data. The tags of the method are its mojom attributes, see
+// transcoder.MojomMethodTags. Since the mojo app must not be connected to
+// before the call is authorized, the tags are only known once the export has
+// been described; until then, the call is authorized without tags, i.e.,
+// against the default access list, and Invoke authorizes it again with them.
+func (fs fakeService) Prepare(ctx *context.T, method string, numArgs int) (argptrs []interface{}, tags []*vdl.Value, _ error) {
+	inargs := make([]*vom.RawBytes, numArgs)
+	inptrs := make([]interface{}, len(inargs))
+	for i := range inargs {
+		inptrs[i] = &inargs[i]
+	}
+	if !fs.export.Allows(method) {
+		// Invoke rejects the call.
+		return inptrs, nil, nil
+	}
+	tags, err := fs.cachedTags(method)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inptrs, tags, nil
+}
+
+// authorizeTags authorizes call, which was authorized without tags by the
+// runtime, with the tags of mm, if it has any.
+func (fs fakeService) authorizeTags(ctx *context.T, call security.Call, mm mojom_types.MojomMethod) error {
+	if len(call.MethodTags()) > 0 {
+		return nil
+	}
+	tags, err := transcoder.MojomMethodTags(mm.DeclData)
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+	auth := fs.d.Auth
+	if auth == nil {
+		auth = security.DefaultAuthorizer()
+	}
+	if err := auth.Authorize(ctx, taggedCall{call, tags}); err != nil {
+		return verror.New(verror.ErrNoAccess, ctx, err)
+	}
+	return nil
+}
+
+// taggedCall is a security.Call with the given method tags.
+type taggedCall struct {
+	security.Call
+	tags []*vdl.Value
+}
+
+func (c taggedCall) MethodTags() []*vdl.Value {
+	return c.tags
+}
+
+// Wraps the interface request and the name of the requested mojo service.
+type v23ServiceRequest struct {
+	request bindings.InterfaceRequest
+	name    string
+}
+
+func (v *v23ServiceRequest) Name() string {
+	return v.name
+}
+
+func (v *v23ServiceRequest) ServiceDescription() service_describer.ServiceDescription {
+	panic("not supported")
+}
+
+func (v *v23ServiceRequest) PassMessagePipe() system.MessagePipeHandle {
+	return v.request.PassMessagePipe()
+}
+
+// Invoke calls the mojom service based on the suffix and converts the mojom
+// results (a struct) to Vanadium results (a slice of *vom.RawBytes).
+// Note: The argptrs from Prepare are reused here. The vom bytes should have
+// been decoded into these argptrs, so there are actual values inside now.
+func (fs fakeService) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) (results []interface{}, _ error) {
+	if !fs.d.Gate.Enter() {
+		return nil, ErrDraining
+	}
+	defer fs.d.Gate.Exit()
+
+	mojourl := fs.export.URL        // e.g., mojo:go_remote_echo_server. May be defined in a BUILD.gn file.
+	mojoname := fs.export.Interface // e.g., mojo::examples::RemoteEcho. Defined from the interface + module.
+	if !fs.export.Allows(method) {
+		return nil, verror.New(verror.ErrUnknownMethod, ctx, method)
+	}
+
+	// Vanadium relies on type information, so we will retrieve that first.
+	// The method is resolved before anything is recorded for it, since its
+	// name comes from the caller.
+	start := time.Now()
+	_, span := vtrace.WithNewSpan(ctx, "describe "+mojoname)
+	mojomInterface, desc, err := fs.describe()
+	span.Finish()
+	describeTime := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	ordinal, mm, ok := findMethod(mojomInterface, method)
+	if !ok {
+		return nil, verror.New(verror.ErrUnknownMethod, ctx, method)
+	}
+	if err := fs.authorizeTags(ctx, call.Security(), mm); err != nil {
+		return nil, err
+	}
+
+	// Create the generic message pipe. r is a bindings.InterfaceRequest, and
+	// p is a bindings.InterfacePointer.
+	r, p := bindings.CreateMessagePipeForMojoInterface()
+	v := v23ServiceRequest{
+		request: r,
+		name:    mojoname,
+	} // v is an application.ServiceRequest with mojoname
+
+	// Connect to the mojourl.
+	fs.d.Apps.ConnectToService(mojourl, &v)
+
+	// Then assign a new router the FakeService.
+	// This will never conflict because each FakeService is only invoked once.
+	fs.router = bindings.NewRouter(p.PassMessagePipe(), bindings.GetAsyncWaiter())
+	s, err := fs.d.Sessions.Add(session.Info{
+		Kind:      "server",
+		Name:      mojourl,
+		Interface: mojoname,
+	}, fs.Close_Proxy)
+	if err != nil {
+		fs.Close_Proxy()
+		return nil, err
+	}
+	defer s.Close()
+
+	ctx.VI(1).Infof("Invoke %s.%s (%s)", mojoname, method, mojourl)
+
+	mc := fs.d.Metrics.Method(metrics.Key{
+		Name:      mojourl,
+		Interface: mojoname,
+		Method:    method,
+	}).Start()
+	mc.Add(metrics.App, describeTime)
+
+	ctx.VI(2).Infof("Invoke %s: interface %v", mojoname, mojomInterface)
+
+	var rec *capture.Record
+	if fs.d.Capture != nil {
+		rec = &capture.Record{
+			Time:      time.Now(),
+			Proxy:     "server",
+			Name:      fs.export.Name,
+			URL:       mojourl,
+			Interface: mojoname,
+			Method:    method,
+		}
+	}
+
+	// With the type information, we can make the method call to the remote interface.
+	methodResults, err := fs.callRemoteMethod(ctx, mc, rec, mojoname, method, ordinal, mm, desc, argptrs)
+	mc.Finish(err)
+	if rec != nil {
+		rec.SetError(err)
+		if err := fs.d.Capture.Write(*rec); err != nil {
+			ctx.Errorf("capturing %s.%s failed: %v", mojoname, method, err)
+		}
+	}
+	if err != nil {
+		ctx.Errorf("Method called failed: %v", err)
+		return nil, err
+	}
+
+	// Convert methodResult to results.
+	results = make([]interface{}, len(methodResults))
+	for i := range methodResults {
+		results[i] = &methodResults[i]
+	}
+	return results, nil
+}
+
+func (fs fakeService) Close_Proxy() {
+	fs.router.Close()
+}
+
+// callRemoteSignature obtains type and header information from the remote
+// mojo service. Remote mojo interfaces all define a signature method.
+func (fs fakeService) callRemoteSignature(mojourl string, mojoname string) (mojomInterface mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType, err error) {
+	// TODO(afandria): The service_describer mojom file defines the constant, but
+	// it is not actually present in the generated code:
+	// https://github.com/domokit/mojo/issues/469
+	// serviceDescriberInterfaceName := "_ServiceDescriber"
+
+	r, p := service_describer.CreateMessagePipeForServiceDescriber()
+	fs.d.Apps.ConnectToService(mojourl, &r)
+	sDescriber := service_describer.NewServiceDescriberProxy(p, bindings.GetAsyncWaiter())
+	defer sDescriber.Close_Proxy()
+
+	r2, p2 := service_describer.CreateMessagePipeForServiceDescription()
+	err = sDescriber.DescribeService(mojoname, r2)
+	if err != nil {
+		return
+	}
+	sDescription := service_describer.NewServiceDescriptionProxy(p2, bindings.GetAsyncWaiter())
+	defer sDescription.Close_Proxy()
+
+	mojomInterface, err = sDescription.GetTopLevelInterface()
+	if err != nil {
+		return
+	}
+	descPtr, err := sDescription.GetAllTypeDefinitions()
+	if err != nil {
+		return
+	}
+	return mojomInterface, *descPtr, nil
+}
+
+// A helper function that sends a remote message that expects a response.
+func (fs fakeService) callRemoteWithResponse(ctx *context.T, message *bindings.Message) (outMessage *bindings.Message, err error) {
+	ctx.VI(2).Infof("callRemoteGeneric: Send message along the router")
+
+	readResult := <-fs.router.AcceptWithResponse(message)
+	if err = readResult.Error; err != nil {
+		return
+	}
+
+	ctx.VI(2).Infof("callRemoteGeneric: Audit response message header flag")
+	// The message flag we receive back must be a bindings.MessageIsResponseFlag
+	if readResult.Message.Header.Flags != bindings.MessageIsResponseFlag {
+		err = &bindings.ValidationError{bindings.MessageHeaderInvalidFlags,
+			fmt.Sprintf("invalid message header flag: %v", readResult.Message.Header.Flags),
+		}
+		return
+	}
+
+	ctx.VI(2).Infof("callRemoteGeneric: Audit response message header type")
+	// While the mojo service we called into will return a header whose
+	// type must match our outgoing one.
+	if got, want := readResult.Message.Header.Type, message.Header.Type; got != want {
+		err = &bindings.ValidationError{bindings.MessageHeaderUnknownMethod,
+			fmt.Sprintf("invalid method in response: expected %v, got %v", want, got),
+		}
+		return
+	}
+
+	return readResult.Message, nil
+}
+
+// findMethod returns the ordinal and the description of the method of mi
+// named method, if there is one.
+func findMethod(mi mojom_types.MojomInterface, method string) (uint32, mojom_types.MojomMethod, bool) {
+	for ordinal, mm := range mi.Methods {
+		if *mm.DeclData.ShortName == method {
+			return ordinal, mm, true
+		}
+	}
+	return 0, mojom_types.MojomMethod{}, false
+}
+
+// callRemoteMethod calls the method, the one with the given ordinal and
+// description, remotely in a generic way.
+// Produces []*vom.RawBytes at the end for the invoker to return.
+// The time spent transcoding and waiting for the mojo app is added to mc, and
+// the payloads of the call to rec, if it is not nil.
+func (fs fakeService) callRemoteMethod(ctx *context.T, mc *metrics.Call, rec *capture.Record, mojoname, method string, ordinal uint32, mm mojom_types.MojomMethod, desc map[string]mojom_types.UserDefinedType, argptrs []interface{}) ([]*vom.RawBytes, error) {
+	logValues := util.MatchInterface(fs.d.LogArgs, mojoname)
+	if logValues || rec != nil {
+		inargs := make([]*vom.RawBytes, len(argptrs))
+		for i := range argptrs {
+			inargs[i] = *argptrs[i].(**vom.RawBytes)
+		}
+		if logValues {
+			util.LogArgs(ctx, "call", mojoname, method, mm.Parameters, desc, inargs)
+		}
+		if rec != nil {
+			rec.SetArgs(inargs)
+		}
+	}
+
+	// A void function must have request id of 0, whereas one with response params
+	// should  have a unique request id.
+	header := bindings.MessageHeader{
+		Type:      ordinal,
+		Flags:     bindings.MessageExpectsResponseFlag,
+		RequestId: fs.ids.Count(),
+	}
+
+	// Now produce the *bindings.Message that we will send to the other side.
+	start := time.Now()
+	_, span := vtrace.WithNewSpan(ctx, "transcode request")
+	inType, err := transcoder.MojomStructToVDLType(mm.Parameters, desc)
+	if err != nil {
+		span.Finish()
+		return nil, err
+	}
+	message, err := encodeMessageFromVom(header, argptrs, inType, fs.desc.defaults)
+	span.Finish()
+	mc.Add(metrics.Transcode, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		rec.Ordinal = ordinal
+		rec.NoResponse = mm.ResponseParams == nil
+		rec.Request = message.Payload
+	}
+
+	// Otherwise, make a generic call with the message. The trace ends with
+	// this span, since the message carries no trace ids to the app.
+	start = time.Now()
+	dispatchCtx, span := vtrace.WithNewSpan(ctx, "mojo dispatch "+method)
+	outMessage, err := fs.callRemoteWithResponse(dispatchCtx, message)
+	span.Finish()
+	mc.Add(metrics.App, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	mc.AddBytes(len(message.Payload), len(outMessage.Payload))
+	if rec != nil {
+		rec.Response = outMessage.Payload
+	}
+
+	// Decode the *vom.RawBytes from the mojom bytes and mojom type.
+	start = time.Now()
+	_, span = vtrace.WithNewSpan(ctx, "transcode response")
+	defer func() {
+		span.Finish()
+		mc.Add(metrics.Transcode, time.Since(start))
+	}()
+	outType, err := transcoder.MojomStructToVDLType(*mm.ResponseParams, desc)
+	if err != nil {
+		return nil, err
+	}
+	target := util.StructSplitTarget()
+	if err := transcoder.FromMojo(target, outMessage.Payload, outType, fs.desc.defaults); err != nil {
+		return nil, fmt.Errorf("transcoder.FromMojo failed: %v", err)
+	}
+	if logValues {
+		util.LogArgs(ctx, "return", mojoname, method, *mm.ResponseParams, desc, target.Fields())
+	}
+	if rec != nil {
+		rec.SetResults(target.Fields())
+	}
+	return target.Fields(), nil
+}
+
+func encodeMessageFromVom(header bindings.MessageHeader, argptrs []interface{}, t *vdl.Type, defaults transcoder.Defaults) (*bindings.Message, error) {
+	// Convert argptrs into their true form: []*vom.RawBytes
+	inargs := make([]*vom.RawBytes, len(argptrs))
+	for i := range argptrs {
+		inargs[i] = *argptrs[i].(**vom.RawBytes)
+	}
+
+	encoder := bindings.NewEncoder()
+	if err := header.Encode(encoder); err != nil {
+		return nil, err
+	}
+	if bytes, handles, err := encoder.Data(); err != nil {
+		return nil, err
+	} else {
+		target := transcoder.ToMojomTarget(defaults)
+		if err := util.JoinRawBytesAsStruct(target, t, inargs); err != nil {
+			return nil, err
+		}
+		moreBytes := target.Bytes()
+		// Append the encoded "payload" to the end of the slice.
+		bytes = append(bytes, moreBytes...)
+
+		return &bindings.Message{
+			Header:  header,
+			Bytes:   bytes,
+			Handles: handles,
+			Payload: moreBytes,
+		}, nil
+	}
+}
+
+// Signature converts the description of the exported mojo interface to VDL,
+// keeping only the methods that the export allows.
+func (fs fakeService) Signature(ctx *context.T, call rpc.ServerCall) ([]signature.Interface, error) {
+	mojomInterface, desc, err := fs.describe()
+	if err != nil {
+		return nil, err
+	}
+	iface, err := transcoder.MojomInterfaceToVDLInterface(mojomInterface, desc)
+	if err != nil {
+		return nil, err
+	}
+	methods := iface.Methods[:0]
+	for _, m := range iface.Methods {
+		if fs.export.Allows(m.Name) {
+			methods = append(methods, m)
+		}
+	}
+	iface.Methods = methods
+	return []signature.Interface{iface}, nil
+}
+
+func (fs fakeService) MethodSignature(ctx *context.T, call rpc.ServerCall, method string) (signature.Method, error) {
+	sig, err := fs.Signature(ctx, call)
+	if err != nil {
+		return signature.Method{}, err
+	}
+	if m, ok := sig[0].FindMethod(method); ok {
+		return m, nil
+	}
+	return signature.Method{}, verror.New(verror.ErrUnknownMethod, ctx, method)
+}
+
+// The fake service will never need to glob.
+func (fs fakeService) Globber() *rpc.GlobState {
+	log.Printf("Fake Service Globber???")
+	return nil
+}
+
+// Dispatcher dispatches Vanadium calls to the exported mojo services. Each
+// call opens a new pipe to the mojo app that serves it.
+type Dispatcher struct {
+	Apps     Apps
+	Sessions *session.Registry
+	Metrics  *metrics.Recorder
+	Gate     *Gate
+	Auth     security.Authorizer
+	Exports  *exports.Registry
+	// LogArgs lists the mojom interfaces whose call arguments are logged,
+	// in the format accepted by util.MatchInterface.
+	LogArgs string
+	// Capture, if not nil, records every call that reaches a mojo app.
+	Capture *capture.Writer
+
+	mu    sync.Mutex
+	descs map[string]*description // by export name
+}
+
+func (d *Dispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	// The runtime has already started a span for the incoming request, which
+	// continues the trace of the caller (usually a v23clientproxy).
+	vtrace.GetSpan(ctx).Annotatef("v23serverproxy: lookup %q", suffix)
+	ctx.VI(1).Infof("dispatcher.Lookup for suffix: %s", suffix)
+	export, err := d.Exports.Resolve(suffix)
+	if err != nil {
+		return nil, nil, verror.New(verror.ErrNoExist, ctx, err.Error())
+	}
+	return fakeService{
+		d:      d,
+		export: export,
+		ids:    bindings.NewCounter(),
+		desc:   d.description(export),
+	}, d.Auth, nil
+}
+
+// description returns the description of export e, which is shared by all
+// calls to it.
+func (d *Dispatcher) description(e exports.Export) *description {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if desc, ok := d.descs[e.Name]; ok && desc.addr == e.Address {
+		return desc
+	}
+	if d.descs == nil {
+		d.descs = map[string]*description{}
+	}
+	desc := &description{addr: e.Address}
+	d.descs[e.Name] = desc
+	return desc
+}
+
+// Forget drops the description of the export called name, which must be
+// called when the export is removed.
+func (d *Dispatcher) Forget(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.descs, name)
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package capture records the calls that pass through a v23proxy to a
+// capture file, so that they can be replayed later against a server proxy or
+// a mojo app, e.g., with the v23replay command, to reproduce bugs without the
+// devices involved.
+//
+// A capture file holds one JSON-encoded Record per line.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"v.io/v23/verror"
+	"v.io/v23/vom"
+)
+
+// Record describes a single call.
+type Record struct {
+	Time time.Time
+	// Proxy is "client" for the calls that a v23clientproxy forwards and
+	// "server" for those that a v23serverproxy serves.
+	Proxy string
+	// Name is the Vanadium name that was called by a client proxy, or the
+	// name under which a server proxy exports the mojo app.
+	Name string
+	// URL is the mojo url of the app that served the call, if known.
+	URL       string `json:",omitempty"`
+	Interface string
+	Method    string
+	// Ordinal identifies the method in mojom messages.
+	Ordinal uint32
+	// NoResponse is true for methods without response params, whose
+	// requests the app does not reply to.
+	NoResponse bool `json:",omitempty"`
+	// Request and Response hold the mojom-encoded params and response
+	// params.
+	Request, Response []byte
+	// Args and Results hold the VOM encoding of each argument and result.
+	Args, Results [][]byte
+	// Error and ErrorID describe the error of the call, if it failed.
+	Error   string    `json:",omitempty"`
+	ErrorID verror.ID `json:",omitempty"`
+
+	// encodeErr is the error of SetArgs or SetResults, if they failed.
+	encodeErr error
+}
+
+// SetError records err as the error of the call.
+func (r *Record) SetError(err error) {
+	if err != nil {
+		r.Error = err.Error()
+		r.ErrorID = verror.ErrorID(err)
+	}
+}
+
+// SetArgs records the VOM encoding of the arguments of the call. If they
+// cannot be encoded, the record is dropped by Writer.Write.
+func (r *Record) SetArgs(args []*vom.RawBytes) {
+	if r.encodeErr == nil {
+		r.Args, r.encodeErr = EncodeArgs(args)
+	}
+}
+
+// SetResults records the VOM encoding of the results of the call. If they
+// cannot be encoded, the record is dropped by Writer.Write.
+func (r *Record) SetResults(results []*vom.RawBytes) {
+	if r.encodeErr == nil {
+		r.Results, r.encodeErr = EncodeArgs(results)
+	}
+}
+
+// Writer writes records to a capture file. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer that writes records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, enc: json.NewEncoder(w)}
+}
+
+// Create returns a Writer that writes records to the file at path, which is
+// truncated if it exists.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f), nil
+}
+
+// Write appends r to the capture. It drops r and returns an error if the
+// arguments or results of r could not be encoded.
+func (w *Writer) Write(r Record) error {
+	if r.encodeErr != nil {
+		return fmt.Errorf("dropped the record: %v", r.encodeErr)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(r)
+}
+
+// Close closes the underlying writer, if it is an io.Closer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Read returns the records in a capture.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record Record
+		switch err := dec.Decode(&record); err {
+		case nil:
+			records = append(records, record)
+		case io.EOF:
+			return records, nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+// EncodeArgs returns the VOM encoding of each of args.
+func EncodeArgs(args []*vom.RawBytes) ([][]byte, error) {
+	encoded := make([][]byte, len(args))
+	for i, arg := range args {
+		b, err := vom.Encode(arg)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+	}
+	return encoded, nil
+}
+
+// DecodeArgs is the inverse of EncodeArgs.
+func DecodeArgs(encoded [][]byte) ([]*vom.RawBytes, error) {
+	args := make([]*vom.RawBytes, len(encoded))
+	for i, b := range encoded {
+		if err := vom.Decode(b, &args[i]); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package capture_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"mojom/tests/end_to_end_test"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vom"
+	"v.io/x/mojo/internal/serverproxy"
+	"v.io/x/mojo/proxy/capture"
+	"v.io/x/mojo/server"
+	"v.io/x/mojo/tests/expected"
+	"v.io/x/mojo/tests/impl"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+func TestReadWrite(t *testing.T) {
+	args, err := capture.EncodeArgs([]*vom.RawBytes{vom.RawBytesOf(int32(1)), vom.RawBytesOf("2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []capture.Record{
+		{
+			Time:      time.Unix(1, 0).UTC(),
+			Proxy:     "client",
+			Name:      "a/b",
+			Interface: "c::D",
+			Method:    "M",
+			Ordinal:   2,
+			Request:   []byte{1, 2, 3},
+			Response:  []byte{4},
+			Args:      args,
+		},
+		{
+			Proxy:   "server",
+			Name:    "e",
+			Method:  "N",
+			Error:   "failed",
+			ErrorID: "v.io/v23/verror.Unknown",
+		},
+	}
+	var buf bytes.Buffer
+	w := capture.NewWriter(&buf)
+	for _, r := range want {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := capture.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	decoded, err := capture.DecodeArgs(got[0].Args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := decoded[1].ToValue(&s); err != nil || s != "2" {
+		t.Errorf("got %q, %v, want %q", s, err, "2")
+	}
+}
+
+func TestCaptureAndReplay(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	d, err := server.NewDispatcher(security.AllowEveryone(), server.Options{}, server.Service{
+		Name:    "test",
+		Factory: impl.ServiceFactory(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	d.(*serverproxy.Dispatcher).Capture = capture.NewWriter(&buf)
+	_, s, err := v23.WithNewDispatchingServer(ctx, "", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := s.Status().Endpoints[0].Name()
+	opts := []rpc.CallOpt{options.ServerAuthorizer{security.AllowEveryone()}}
+
+	var value string
+	if err := v23.GetClient(ctx).Call(ctx, naming.Join(ep, "test"), "Simple", []interface{}{expected.SimpleRequestA}, []interface{}{&value}, opts...); err != nil {
+		t.Fatal(err)
+	}
+	records, err := capture.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	r := records[0]
+	if r.Proxy != "server" || r.Name != "test" || r.Method != "Simple" || r.Request == nil || r.Response == nil || len(r.Args) != 1 || len(r.Results) != 1 {
+		t.Errorf("unexpected record %+v", r)
+	}
+
+	name := func(r capture.Record) string { return naming.Join(ep, r.Name) }
+	for _, result := range capture.ReplayV23(ctx, name, records, opts...) {
+		if result.Diff != "" {
+			t.Errorf("ReplayV23: %s", result.Diff)
+		}
+	}
+	mojoReplay := func() []capture.Result {
+		r, p := end_to_end_test.CreateMessagePipeForV23ProxyTest()
+		impl.Factory{}.Create(r)
+		return capture.ReplayMojo(ctx, p.PassMessagePipe(), records)
+	}
+	for _, result := range mojoReplay() {
+		if result.Diff != "" {
+			t.Errorf("ReplayMojo: %s", result.Diff)
+		}
+	}
+
+	// A record whose results differ from the service's is reported.
+	changed, err := capture.EncodeArgs([]*vom.RawBytes{vom.RawBytesOf(value + "!")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records[0].Results = changed
+	records[0].Response = append([]byte(nil), r.Response...)
+	records[0].Response[len(r.Response)-1]++
+	if result := capture.ReplayV23(ctx, name, records, opts...)[0]; result.Diff == "" {
+		t.Errorf("ReplayV23 reported no difference for a changed result")
+	}
+	if result := mojoReplay()[0]; result.Diff == "" {
+		t.Errorf("ReplayMojo reported no difference for a changed response")
+	}
+}
+
+func TestReplayMojoStops(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	records := []capture.Record{
+		{Method: "Simple", Request: []byte{1}, Response: []byte{2}},
+		{Method: "Simple", Request: []byte{1}, Response: []byte{2}},
+	}
+
+	// An app that never responds.
+	r, p := end_to_end_test.CreateMessagePipeForV23ProxyTest()
+	defer r.PassMessagePipe().Close()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	for i, result := range capture.ReplayMojo(timeoutCtx, p.PassMessagePipe(), records) {
+		if result.Diff == "" {
+			t.Errorf("timeout: record %d: got no difference", i)
+		}
+	}
+
+	// An app that closed the pipe.
+	r, p = end_to_end_test.CreateMessagePipeForV23ProxyTest()
+	r.PassMessagePipe().Close()
+	results := capture.ReplayMojo(ctx, p.PassMessagePipe(), records)
+	if got, want := results[1].Diff, "not replayed: the app closed the pipe"; got != want {
+		t.Errorf("closed pipe: got %q, want %q", got, want)
+	}
+
+	// Requests of methods without a response are sent without waiting for
+	// one.
+	r, p = end_to_end_test.CreateMessagePipeForV23ProxyTest()
+	defer r.PassMessagePipe().Close()
+	noResponse := []capture.Record{{Method: "Put", Request: []byte{1}, NoResponse: true}}
+	if result := capture.ReplayMojo(ctx, p.PassMessagePipe(), noResponse)[0]; result.Diff != "" {
+		t.Errorf("no response: got %q, want no difference", result.Diff)
+	}
+}
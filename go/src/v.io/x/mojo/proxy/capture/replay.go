@@ -0,0 +1,185 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"mojo/public/go/bindings"
+	"mojo/public/go/system"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/vdl"
+	"v.io/v23/verror"
+	"v.io/v23/vom"
+)
+
+// Result is the outcome of replaying a record.
+type Result struct {
+	Record
+	// Diff describes how the replayed call differs from the record; it is
+	// empty if they match.
+	Diff string
+}
+
+// ReplayV23 repeats records as Vanadium calls, e.g., to a v23serverproxy,
+// calling name(r) for each record r, and compares the results with the
+// recorded ones. Results are compared as values, so differences in their VOM
+// encoding are ignored.
+func ReplayV23(ctx *context.T, name func(Record) string, records []Record, opts ...rpc.CallOpt) []Result {
+	results := make([]Result, len(records))
+	for i, r := range records {
+		results[i] = Result{Record: r, Diff: replayV23(ctx, name(r), r, opts)}
+	}
+	return results
+}
+
+func replayV23(ctx *context.T, name string, r Record, opts []rpc.CallOpt) string {
+	args, err := DecodeArgs(r.Args)
+	if err != nil {
+		return fmt.Sprintf("cannot decode the recorded arguments: %v", err)
+	}
+	argsIfc := make([]interface{}, len(args))
+	for i := range args {
+		argsIfc[i] = args[i]
+	}
+	outargs := make([]*vom.RawBytes, len(r.Results))
+	outptrs := make([]interface{}, len(outargs))
+	for i := range outargs {
+		outptrs[i] = &outargs[i]
+	}
+	err = v23.GetClient(ctx).Call(ctx, name, r.Method, argsIfc, outptrs, opts...)
+	switch {
+	case err != nil && r.Error == "":
+		return fmt.Sprintf("got error %v, want success", err)
+	case err != nil:
+		if id := verror.ErrorID(err); id != r.ErrorID {
+			return fmt.Sprintf("got error %v, want error %s", err, r.Error)
+		}
+		return ""
+	case r.Error != "":
+		return fmt.Sprintf("got success, want error %s", r.Error)
+	}
+	want, err := DecodeArgs(r.Results)
+	if err != nil {
+		return fmt.Sprintf("cannot decode the recorded results: %v", err)
+	}
+	var diffs []string
+	for i := range want {
+		got, err := rawToValue(outargs[i])
+		if err != nil {
+			return fmt.Sprintf("cannot decode result %d: %v", i, err)
+		}
+		wantValue, err := rawToValue(want[i])
+		if err != nil {
+			return fmt.Sprintf("cannot decode recorded result %d: %v", i, err)
+		}
+		if !vdl.EqualValue(got, wantValue) {
+			diffs = append(diffs, fmt.Sprintf("result %d: got %v, want %v", i, got, wantValue))
+		}
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func rawToValue(raw *vom.RawBytes) (*vdl.Value, error) {
+	var v *vdl.Value
+	if raw == nil {
+		return v, nil
+	}
+	err := raw.ToValue(&v)
+	return v, err
+}
+
+// ReplayMojo sends the recorded mojom requests to the mojo app at the other
+// end of handle and compares its responses with the recorded ones byte for
+// byte. Records whose call failed before a response was recorded only check
+// that the app does not respond either, and the requests of records without
+// a response are only sent. Replaying stops when ctx is done or the app
+// closes the pipe, and the records that were not replayed are reported as
+// such. ReplayMojo closes handle.
+func ReplayMojo(ctx *context.T, handle system.MessagePipeHandle, records []Record) []Result {
+	router := bindings.NewRouter(handle, bindings.GetAsyncWaiter())
+	defer router.Close()
+	ids := bindings.NewCounter()
+	results := make([]Result, len(records))
+	var stopped string
+	for i, r := range records {
+		if stopped != "" {
+			results[i] = Result{Record: r, Diff: "not replayed: " + stopped}
+			continue
+		}
+		var diff string
+		diff, stopped = replayMojo(ctx, router, ids.Count(), r)
+		results[i] = Result{Record: r, Diff: diff}
+	}
+	return results
+}
+
+// replayMojo replays r and returns its diff, and why replaying must stop, if
+// it must.
+func replayMojo(ctx *context.T, router *bindings.Router, id uint64, r Record) (diff, stop string) {
+	if r.Request == nil {
+		return "no mojom request was recorded", ""
+	}
+	// As in generated code, requests without a response have no flags and
+	// request id 0.
+	header := bindings.MessageHeader{Type: r.Ordinal}
+	if !r.NoResponse {
+		header.Flags = bindings.MessageExpectsResponseFlag
+		header.RequestId = id
+	}
+	encoder := bindings.NewEncoder()
+	if err := header.Encode(encoder); err != nil {
+		return err.Error(), ""
+	}
+	data, handles, err := encoder.Data()
+	if err != nil {
+		return err.Error(), ""
+	}
+	message := &bindings.Message{
+		Header:  header,
+		Bytes:   append(data, r.Request...),
+		Handles: handles,
+		Payload: r.Request,
+	}
+	if r.NoResponse {
+		if err := router.Accept(message); err != nil {
+			if connectionError, ok := err.(*bindings.ConnectionError); ok && connectionError.Closed() {
+				stop = "the app closed the pipe"
+			}
+			return fmt.Sprintf("sending the request failed: %v", err), stop
+		}
+		return "", ""
+	}
+	var readResult bindings.MessageReadResult
+	select {
+	case readResult = <-router.AcceptWithResponse(message):
+	case <-ctx.Done():
+		if r.Response == nil {
+			return "", ctx.Err().Error()
+		}
+		return fmt.Sprintf("got no response, want one: %v", ctx.Err()), ctx.Err().Error()
+	}
+	if connectionError, ok := readResult.Error.(*bindings.ConnectionError); ok && connectionError.Closed() {
+		stop = "the app closed the pipe"
+	}
+	switch {
+	case readResult.Error != nil && r.Response == nil:
+		return "", stop
+	case readResult.Error != nil:
+		return fmt.Sprintf("got error %v, want a response", readResult.Error), stop
+	case r.Response == nil:
+		return fmt.Sprintf("got a response, want error %s", r.Error), stop
+	case readResult.Message.Header.Type != r.Ordinal:
+		return fmt.Sprintf("got a response to method %d, want %d", readResult.Message.Header.Type, r.Ordinal), stop
+	case !bytes.Equal(readResult.Message.Payload, r.Response):
+		return fmt.Sprintf("got response %x, want %x", readResult.Message.Payload, r.Response), stop
+	}
+	return "", stop
+}
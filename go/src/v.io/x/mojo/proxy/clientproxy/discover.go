@@ -0,0 +1,66 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"mojom/v23clientproxy"
+
+	vdiscovery "v.io/v23/discovery"
+	"v.io/x/mojo/discovery"
+	"v.io/x/ref/lib/discovery/factory"
+)
+
+// scanner creates the discovery instance on first use, since most apps never
+// look for services.
+type scanner struct {
+	once sync.Once
+	d    vdiscovery.T
+	err  error
+}
+
+func (s *scanner) get() (vdiscovery.T, error) {
+	s.once.Do(func() {
+		s.d, s.err = factory.New()
+	})
+	return s.d, s.err
+}
+
+func (s *scanner) close() {
+	if s.d != nil {
+		s.d.Close()
+	}
+}
+
+// FindServices reports a failed scan in outError, since an error would close
+// the pipe of the app.
+func (r *v23HeaderReceiver) FindServices(interfaceName string, timeoutMs uint32) (outInstances []v23clientproxy.ServiceInstance, outError *string, err error) {
+	outInstances = []v23clientproxy.ServiceInstance{}
+	d, err := r.delegate.scanner.get()
+	if err != nil {
+		msg := err.Error()
+		return outInstances, &msg, nil
+	}
+	services, err := discovery.Scan(r.delegate.ctx, d, interfaceName, time.Duration(timeoutMs)*time.Millisecond)
+	if err != nil {
+		msg := err.Error()
+		return outInstances, &msg, nil
+	}
+	for _, s := range services {
+		attrs := map[string]string{}
+		for k, v := range s.Attrs {
+			attrs[k] = v
+		}
+		outInstances = append(outInstances, v23clientproxy.ServiceInstance{
+			InstanceName:  s.InstanceName,
+			InterfaceName: s.InterfaceName,
+			Attributes:    attrs,
+			V23Names:      append([]string{}, s.Addrs...),
+		})
+	}
+	return outInstances, nil, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"mojo/public/go/bindings"
+	"mojo/public/go/system"
+	"mojo/public/interfaces/bindings/service_describer"
+
+	"mojom/v23clientproxy"
+
+	"v.io/x/mojo/internal/clientproxy"
+	"v.io/x/mojo/proxy/session"
+)
+
+func (r *v23HeaderReceiver) SetupVanadiumProxy(v23Name string, handle system.MessagePipeHandle, options v23clientproxy.ClientProxyOptions) (outError *string, err error) {
+	opts, err := clientproxy.ParseOptions(options)
+	if err != nil {
+		handle.Close()
+	} else {
+		err = r.delegate.proxy.SetupVanadium(r.delegate.ctx, v23Name, handle, opts)
+	}
+	if err != nil {
+		msg := err.Error()
+		return &msg, nil
+	}
+	return nil, nil
+}
+
+// describerFactory serves ServiceDescriber requests for Vanadium services.
+// The interface name passed to DescribeService is the v23 name of the
+// service, and the description is converted from its signature.
+type describerFactory struct {
+	delegate *delegate
+}
+
+func (f *describerFactory) Create(request service_describer.ServiceDescriber_Request) {
+	stub := service_describer.NewServiceDescriberStub(request, &vanadiumDescriber{f.delegate}, bindings.GetAsyncWaiter())
+	f.delegate.serve(stub, session.Info{Kind: "describer"})
+}
+
+type vanadiumDescriber struct {
+	delegate *delegate
+}
+
+func (d *vanadiumDescriber) DescribeService(v23Name string, request service_describer.ServiceDescription_Request) (err error) {
+	ctx := d.delegate.ctx
+	iface, desc, err := clientproxy.DescribeVanadiumService(ctx, v23Name, clientproxy.Options{})
+	if err != nil {
+		// The app sees the description pipe close.
+		ctx.Errorf("describing %s: %v", v23Name, err)
+		request.PassMessagePipe().Close()
+		return nil
+	}
+	stub := service_describer.NewServiceDescriptionStub(request, &clientproxy.Description{Interface: iface, Types: desc}, bindings.GetAsyncWaiter())
+	d.delegate.serve(stub, session.Info{Kind: "describer", Name: v23Name})
+	return nil
+}
+
+// serve serves requests on stub until the app closes its end of the pipe.
+func (delegate *delegate) serve(stub *bindings.Stub, info session.Info) {
+	s, err := delegate.sessions.Add(info, func() { stub.Close() })
+	if err != nil {
+		delegate.ctx.Errorf("rejecting %s connection: %v", info.Kind, err)
+		stub.Close()
+		return
+	}
+	go func() {
+		defer s.Close()
+		for {
+			if err := stub.ServeRequest(); err != nil {
+				connectionError, ok := err.(*bindings.ConnectionError)
+				if !ok || !connectionError.Closed() {
+					delegate.ctx.Errorf("%v", err)
+				}
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,223 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package exports keeps track of the mojo services that a server proxy makes
+// reachable over Vanadium. Calls for anything that has not been exported are
+// rejected, so that remote callers cannot make the shell load arbitrary apps.
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"v.io/x/mojo/proxy/util"
+)
+
+var (
+	// ErrNotExported is returned by Resolve for suffixes that do not match an
+	// export.
+	ErrNotExported = errors.New("exports: not exported")
+	// ErrExists is returned by Add when the name is already exported.
+	ErrExists = errors.New("exports: name already exported")
+	// ErrAddressExists is returned by Add when the address is already
+	// exported with other methods, since Resolve could not tell the exports
+	// apart.
+	ErrAddressExists = errors.New("exports: address already exported with other methods")
+)
+
+// Export describes a mojo interface that is reachable through the proxy.
+type Export struct {
+	// Name is the single name element at which the export is served.
+	Name string
+	util.Address
+	// Methods lists the methods that may be called. If empty, every method
+	// may be called.
+	Methods []string
+}
+
+// Allows returns true if method may be called on the export.
+func (e Export) Allows(method string) bool {
+	if len(e.Methods) == 0 {
+		return true
+	}
+	for _, m := range e.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that e can be added to a Registry.
+func (e Export) Validate() error {
+	switch {
+	case e.Name == "" || strings.Contains(e.Name, "/"):
+		return fmt.Errorf("invalid export name %q", e.Name)
+	case e.URL == "":
+		return fmt.Errorf("export %q has no mojo url", e.Name)
+	case e.Interface == "":
+		return fmt.Errorf("export %q has no interface", e.Name)
+	}
+	return nil
+}
+
+// Registry is a concurrency-safe set of exports.
+type Registry struct {
+	mu      sync.RWMutex
+	exports map[string]Export
+}
+
+// NewRegistry returns a registry holding exports.
+func NewRegistry(exports ...Export) (*Registry, error) {
+	r := &Registry{exports: map[string]Export{}}
+	for _, e := range exports {
+		if err := r.Add(e); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add registers e. It fails if e is invalid, its name is already taken, or
+// its address is exported with other methods.
+func (r *Registry) Add(e Export) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	e.Methods = append([]string(nil), e.Methods...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.exports[e.Name]; ok {
+		return ErrExists
+	}
+	for _, other := range r.exports {
+		if other.Address == e.Address && !sameMethods(other.Methods, e.Methods) {
+			return ErrAddressExists
+		}
+	}
+	r.exports[e.Name] = e
+	return nil
+}
+
+// sameMethods returns true if a and b list the same methods, in any order.
+func sameMethods(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove unregisters the export called name and reports whether it existed.
+func (r *Registry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.exports[name]
+	delete(r.exports, name)
+	return ok
+}
+
+// List returns the exports, sorted by name.
+func (r *Registry) List() []Export {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Export, 0, len(r.exports))
+	for _, e := range r.exports {
+		list = append(list, e)
+	}
+	sort.Sort(byName(list))
+	return list
+}
+
+// Resolve returns the export addressed by suffix, which is either the name
+// of an export or the address of an exported interface as returned by
+// util.EncodeSuffix. The exports of an address all allow the same methods,
+// see Add, so it does not matter which one is returned for an address.
+func (r *Registry) Resolve(suffix string) (Export, error) {
+	suffix = strings.Trim(suffix, "/")
+	if !strings.Contains(suffix, "/") {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		if e, ok := r.exports[suffix]; ok {
+			return e, nil
+		}
+		return Export{}, ErrNotExported
+	}
+	addr, err := util.DecodeSuffix(suffix, nil)
+	if err != nil {
+		return Export{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.exports {
+		if e.Address == addr {
+			return e, nil
+		}
+	}
+	return Export{}, ErrNotExported
+}
+
+// Parse parses a comma-separated list of exports, each of the form
+//
+//	<name>=<escaped url>/<escaped interface>[#<method>[#<method>...]]
+//
+// e.g., echo=https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho#EchoString
+func Parse(spec string) ([]Export, error) {
+	var exports []Export
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid export %q, want <name>=<escaped url>/<escaped interface>[#<method>...]", item)
+		}
+		// Interface names cannot contain '#', so the methods follow the first
+		// '#' after the last '/'.
+		suffix, methods := parts[1], []string(nil)
+		if i := strings.LastIndex(suffix, "/"); i >= 0 {
+			if j := strings.Index(suffix[i:], "#"); j >= 0 {
+				methods = strings.Split(suffix[i+j+1:], "#")
+				suffix = suffix[:i+j]
+			}
+		}
+		addr, err := util.DecodeSuffix(suffix, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid export %q: %v", item, err)
+		}
+		e := Export{Name: parts[0], Address: addr, Methods: methods}
+		if err := e.Validate(); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, nil
+}
+
+// FromAliases returns an export of every method for each of the aliases
+// returned by util.ParseAliases, sorted by name.
+func FromAliases(aliases map[string]util.Address) []Export {
+	list := make([]Export, 0, len(aliases))
+	for name, addr := range aliases {
+		list = append(list, Export{Name: name, Address: addr})
+	}
+	sort.Sort(byName(list))
+	return list
+}
+
+type byName []Export
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,151 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package exports_test
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/util"
+)
+
+var (
+	echoAddr    = util.Address{URL: "https://mojo.v.io/echo_server.mojo", Interface: "mojo::examples::RemoteEcho"}
+	fortuneAddr = util.Address{URL: "https://mojo.v.io/fortune_server.mojo", Interface: "mojo::examples::Fortune"}
+)
+
+func TestResolve(t *testing.T) {
+	r, err := exports.NewRegistry(
+		exports.Export{Name: "echo", Address: echoAddr},
+		exports.Export{Name: "fortune", Address: fortuneAddr, Methods: []string{"Get"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		suffix string
+		want   string
+		ok     bool
+	}{
+		{"echo", "echo", true},
+		{"fortune", "fortune", true},
+		{util.EncodeSuffix(echoAddr), "echo", true},
+		{"unknown", "", false},
+		{util.EncodeSuffix(util.Address{URL: "mojo:evil", Interface: "mojo::examples::RemoteEcho"}), "", false},
+		{"not/a/valid/suffix", "", false},
+	}
+	for _, test := range tests {
+		e, err := r.Resolve(test.suffix)
+		if (err == nil) != test.ok {
+			t.Errorf("Resolve(%q) returned error %v, want ok %v", test.suffix, err, test.ok)
+			continue
+		}
+		if got := e.Name; got != test.want {
+			t.Errorf("Resolve(%q) = %q, want %q", test.suffix, got, test.want)
+		}
+	}
+
+	if !r.Remove("echo") {
+		t.Errorf("Remove(echo) = false, want true")
+	}
+	if _, err := r.Resolve("echo"); err != exports.ErrNotExported {
+		t.Errorf("got error %v after Remove, want %v", err, exports.ErrNotExported)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	r, err := exports.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(exports.Export{Name: "echo", Address: echoAddr}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(exports.Export{Name: "echo", Address: fortuneAddr}); err != exports.ErrExists {
+		t.Errorf("got error %v, want %v", err, exports.ErrExists)
+	}
+	for _, bad := range []exports.Export{
+		{Name: "", Address: echoAddr},
+		{Name: "a/b", Address: echoAddr},
+		{Name: "x", Address: util.Address{URL: "mojo:x"}},
+	} {
+		if err := r.Add(bad); err == nil {
+			t.Errorf("Add(%v) should have failed", bad)
+		}
+	}
+	if got, want := len(r.List()), 1; got != want {
+		t.Errorf("got %d exports, want %d", got, want)
+	}
+}
+
+func TestAddSameAddress(t *testing.T) {
+	r, err := exports.NewRegistry(exports.Export{Name: "fortune", Address: fortuneAddr, Methods: []string{"Get", "Add"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another name for the same methods is fine.
+	if err := r.Add(exports.Export{Name: "f", Address: fortuneAddr, Methods: []string{"Add", "Get"}}); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	// Other methods of the same address could be reached through either
+	// export by address.
+	for _, methods := range [][]string{nil, {"Get"}} {
+		if err := r.Add(exports.Export{Name: "readonly", Address: fortuneAddr, Methods: methods}); err != exports.ErrAddressExists {
+			t.Errorf("Add with methods %v: got error %v, want %v", methods, err, exports.ErrAddressExists)
+		}
+	}
+	e, err := r.Resolve(util.EncodeSuffix(fortuneAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Allows("Remove") || !e.Allows("Get") || !e.Allows("Add") {
+		t.Errorf("Resolve by address returned %v", e)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	all := exports.Export{Name: "echo", Address: echoAddr}
+	some := exports.Export{Name: "fortune", Address: fortuneAddr, Methods: []string{"Get"}}
+	if !all.Allows("EchoString") {
+		t.Errorf("an export without methods should allow every method")
+	}
+	if !some.Allows("Get") || some.Allows("Add") {
+		t.Errorf("got Allows(Get) = %v, Allows(Add) = %v, want true, false", some.Allows("Get"), some.Allows("Add"))
+	}
+}
+
+func TestParse(t *testing.T) {
+	got, err := exports.Parse("echo=https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho, fortune=https:%2F%2Fmojo.v.io%2Ffortune_server.mojo/mojo::examples::Fortune#Get#Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []exports.Export{
+		{Name: "echo", Address: echoAddr},
+		{Name: "fortune", Address: fortuneAddr, Methods: []string{"Get", "Add"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"echo", "=mojo:echo_server/iface", "a/b=mojo:echo_server/iface", "echo=mojo:echo_server"} {
+		if _, err := exports.Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should have failed", bad)
+		}
+	}
+}
+
+func TestFromAliases(t *testing.T) {
+	aliases, err := util.ParseAliases("fortune=https:%2F%2Fmojo.v.io%2Ffortune_server.mojo/mojo::examples::Fortune,echo=https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []exports.Export{
+		{Name: "echo", Address: echoAddr},
+		{Name: "fortune", Address: fortuneAddr},
+	}
+	if got := exports.FromAliases(aliases); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
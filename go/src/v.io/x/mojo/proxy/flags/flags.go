@@ -0,0 +1,21 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flags defines the command-line flags that v23clientproxy and
+// v23serverproxy share, so that they are documented in one place.
+package flags
+
+import "flag"
+
+// LogArgs lists the mojom interfaces whose decoded arguments and results are
+// logged on every call, in the format accepted by util.MatchInterface. Fields
+// marked [Sensitive] are redacted. Other per-call logging is only enabled with
+// -v=1 (calls) or -v=2 (types).
+var LogArgs = flag.String("log-args", "", "comma-separated mojom interface names whose call arguments are logged, or * for all")
+
+// Capture names the file to which every call that the proxy forwards is
+// recorded, so that it can be replayed with v23replay. Captures hold the
+// payloads of calls, including [Sensitive] fields, so they should be treated
+// like the data they contain.
+var Capture = flag.String("capture", "", "file to record every call to, for v23replay")
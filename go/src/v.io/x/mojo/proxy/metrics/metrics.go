@@ -0,0 +1,234 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics records per-method counters and latency histograms for the
+// calls that pass through a v23proxy.
+//
+// Every method gets a set of objects in Vanadium's stats registry, named
+//   <prefix>/<remote name>/<interface>/<method>/<stat>
+// where the remote name and interface are query-escaped so that they form a
+// single name element. The stats include a latency histogram for the whole
+// call and one for each phase that the Recorder measures. On a server these
+// are visible through the __debug/stats interface. A Recorder can also be
+// snapshotted for callers that have no access to the stats registry.
+package metrics
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"v.io/x/ref/lib/stats"
+	"v.io/x/ref/lib/stats/counter"
+	"v.io/x/ref/lib/stats/histogram"
+)
+
+// Phase identifies where the time of a call was spent.
+type Phase int
+
+const (
+	// Transcode is the time spent converting between mojom and VOM.
+	Transcode Phase = iota
+	// Network is the time spent in the Vanadium RPC.
+	Network
+	// App is the time spent waiting for the mojo app.
+	App
+	numPhases
+)
+
+func (p Phase) String() string {
+	switch p {
+	case Transcode:
+		return "transcode"
+	case Network:
+		return "network"
+	case App:
+		return "app"
+	}
+	return "unknown"
+}
+
+// Key identifies a proxied method.
+type Key struct {
+	Name      string // The v23 name or mojo url on the other end.
+	Interface string
+	Method    string
+}
+
+// Snapshot holds the values recorded for a method.
+type Snapshot struct {
+	Key
+	Calls    int64
+	Errors   int64
+	BytesIn  int64
+	BytesOut int64
+	// Time holds the cumulative time spent in each phase.
+	Time [numPhases]time.Duration
+}
+
+// Recorder holds the metrics of every method seen so far.
+type Recorder struct {
+	prefix string
+	phases []Phase
+
+	mu      sync.Mutex
+	methods map[Key]*Method
+}
+
+// NewRecorder returns a Recorder whose stats are named under prefix, e.g.,
+// "v23proxy/server", with latency histograms for phases. Time added to other
+// phases only shows up in snapshots. Recorders in the same process need
+// distinct prefixes.
+func NewRecorder(prefix string, phases ...Phase) *Recorder {
+	return &Recorder{
+		prefix:  prefix,
+		phases:  phases,
+		methods: map[Key]*Method{},
+	}
+}
+
+// Method returns the metrics of the method identified by key, creating them
+// if necessary.
+func (r *Recorder) Method(key Key) *Method {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.methods[key]; ok {
+		return m
+	}
+	m := newMethod(r.prefix, r.phases, key)
+	r.methods[key] = m
+	return m
+}
+
+// Snapshot returns the current values for every method, sorted by key.
+func (r *Recorder) Snapshot() []Snapshot {
+	r.mu.Lock()
+	methods := make([]*Method, 0, len(r.methods))
+	for _, m := range r.methods {
+		methods = append(methods, m)
+	}
+	r.mu.Unlock()
+
+	snaps := make([]Snapshot, len(methods))
+	for i, m := range methods {
+		snaps[i] = m.Snapshot()
+	}
+	sort.Sort(byKey(snaps))
+	return snaps
+}
+
+// Method holds the metrics of a single proxied method.
+type Method struct {
+	calls, errors, bytesIn, bytesOut *counter.Counter
+	total                            *histogram.Histogram
+	latency                          [numPhases]*histogram.Histogram // nil for phases that are not measured
+
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+func newMethod(prefix string, phases []Phase, key Key) *Method {
+	name := prefix + "/" + url.QueryEscape(key.Name) + "/" + url.QueryEscape(key.Interface) + "/" + key.Method + "/"
+	m := &Method{
+		calls:    stats.NewCounter(name + "calls"),
+		errors:   stats.NewCounter(name + "errors"),
+		bytesIn:  stats.NewCounter(name + "bytes-in"),
+		bytesOut: stats.NewCounter(name + "bytes-out"),
+		total:    newHistogram(name + "latency-us"),
+		snap:     Snapshot{Key: key},
+	}
+	for _, p := range phases {
+		m.latency[p] = newHistogram(name + "latency-" + p.String() + "-us")
+	}
+	return m
+}
+
+func newHistogram(name string) *histogram.Histogram {
+	return stats.NewHistogram(name, histogram.Options{
+		NumBuckets:         32,
+		GrowthFactor:       0.5,
+		SmallestBucketSize: 1,
+		MinValue:           0,
+	})
+}
+
+// Start begins measuring a call to the method.
+func (m *Method) Start() *Call {
+	return &Call{method: m, start: time.Now()}
+}
+
+// Snapshot returns the current values of the method's metrics.
+func (m *Method) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snap
+}
+
+func (m *Method) record(c *Call, failed bool) {
+	m.calls.Incr(1)
+	m.bytesIn.Incr(c.bytesIn)
+	m.bytesOut.Incr(c.bytesOut)
+	if failed {
+		m.errors.Incr(1)
+	}
+	m.total.Add(int64(time.Since(c.start) / time.Microsecond))
+	for p, d := range c.time {
+		if m.latency[p] != nil {
+			m.latency[p].Add(int64(d / time.Microsecond))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snap.Calls++
+	m.snap.BytesIn += c.bytesIn
+	m.snap.BytesOut += c.bytesOut
+	if failed {
+		m.snap.Errors++
+	}
+	for p, d := range c.time {
+		m.snap.Time[p] += d
+	}
+}
+
+// Call accumulates the measurements of a single call. It is not safe for
+// concurrent use.
+type Call struct {
+	method            *Method
+	start             time.Time
+	time              [numPhases]time.Duration
+	bytesIn, bytesOut int64
+}
+
+// Add attributes d to phase p.
+func (c *Call) Add(p Phase, d time.Duration) {
+	c.time[p] += d
+}
+
+// AddBytes records the size of the request and response payloads.
+func (c *Call) AddBytes(in, out int) {
+	c.bytesIn += int64(in)
+	c.bytesOut += int64(out)
+}
+
+// Finish records the call. err is the error that the call failed with, if
+// any.
+func (c *Call) Finish(err error) {
+	c.method.record(c, err != nil)
+}
+
+type byKey []Snapshot
+
+func (b byKey) Len() int      { return len(b) }
+func (b byKey) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byKey) Less(i, j int) bool {
+	switch {
+	case b[i].Name != b[j].Name:
+		return b[i].Name < b[j].Name
+	case b[i].Interface != b[j].Interface:
+		return b[i].Interface < b[j].Interface
+	}
+	return b[i].Method < b[j].Method
+}
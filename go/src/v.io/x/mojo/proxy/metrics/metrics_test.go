@@ -0,0 +1,72 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/ref/lib/stats"
+)
+
+func TestRecorder(t *testing.T) {
+	r := metrics.NewRecorder("metrics-test", metrics.Transcode, metrics.Network)
+	echo := metrics.Key{"https://mojo.v.io/echo_server.mojo", "mojo::examples::RemoteEcho", "EchoString"}
+	fortune := metrics.Key{"https://mojo.v.io/fortune_server.mojo", "mojo::examples::Fortune", "Get"}
+
+	c := r.Method(echo).Start()
+	c.Add(metrics.Transcode, 2*time.Millisecond)
+	c.Add(metrics.Network, 5*time.Millisecond)
+	c.Add(metrics.Transcode, time.Millisecond)
+	c.AddBytes(16, 24)
+	c.Finish(nil)
+
+	c = r.Method(echo).Start()
+	c.AddBytes(16, 0)
+	c.Finish(fmt.Errorf("failed"))
+
+	r.Method(fortune).Start().Finish(nil)
+
+	snaps := r.Snapshot()
+	if got, want := len(snaps), 2; got != want {
+		t.Fatalf("got %d snapshots, want %d", got, want)
+	}
+	got := snaps[0]
+	want := metrics.Snapshot{
+		Key:      echo,
+		Calls:    2,
+		Errors:   1,
+		BytesIn:  32,
+		BytesOut: 24,
+	}
+	want.Time[metrics.Transcode] = 3 * time.Millisecond
+	want.Time[metrics.Network] = 5 * time.Millisecond
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got, want := snaps[1].Key, fortune; got != want {
+		t.Errorf("got key %v, want %v", got, want)
+	}
+
+	const prefix = "metrics-test/https%3A%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo%3A%3Aexamples%3A%3ARemoteEcho/EchoString/"
+	calls, err := stats.Value(prefix + "calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls, int64(2); got != want {
+		t.Errorf("got %v calls in the stats registry, want %v", got, want)
+	}
+	// Only the total and the measured phases have histograms.
+	for _, name := range []string{"latency-us", "latency-transcode-us", "latency-network-us"} {
+		if _, err := stats.Value(prefix + name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := stats.Value(prefix + "latency-app-us"); err == nil {
+		t.Errorf("latency-app-us is in the stats registry")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"mojo/public/go/bindings"
+
+	"mojom/v23serverproxy"
+
+	"v.io/v23/context"
+	"v.io/v23/security"
+	"v.io/v23/security/access"
+	"v.io/x/lib/vlog"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+)
+
+// aclAuthorizer authorizes calls against access lists that can be replaced
+// at runtime. Calls to methods with an access tag, e.g., [Access="Read"] in
+// mojom, are authorized against the list for the tag, if there is one, and
+// other calls against the default list. A nil access list allows everyone.
+type aclAuthorizer struct {
+	mu   sync.RWMutex
+	acl  *access.AccessList
+	tags map[access.Tag]*access.AccessList
+}
+
+func (a *aclAuthorizer) Authorize(ctx *context.T, call security.Call) error {
+	a.mu.RLock()
+	acl := a.acl
+	for _, tag := range call.MethodTags() {
+		if tag.Type() != access.TypicalTagType() {
+			continue
+		}
+		if tagACL, ok := a.tags[access.Tag(tag.RawString())]; ok {
+			acl = tagACL
+			break
+		}
+	}
+	a.mu.RUnlock()
+	if acl == nil {
+		return security.AllowEveryone().Authorize(ctx, call)
+	}
+	return acl.Authorize(ctx, call)
+}
+
+// update replaces the access list for tag, or the default list if tag is
+// empty. An empty in allows everyone, or, for a tag, removes its list, so it
+// cannot be combined with notIn.
+func (a *aclAuthorizer) update(tag access.Tag, in, notIn []string) error {
+	if tag != "" && !isTypicalTag(tag) {
+		return fmt.Errorf("invalid access tag %q, want one of %v", tag, access.AllTypicalTags())
+	}
+	if len(in) == 0 && len(notIn) > 0 {
+		return fmt.Errorf("not in %v without any in patterns would allow everyone", notIn)
+	}
+	for _, name := range notIn {
+		// Blessing names are the patterns that only match themselves and
+		// their extensions.
+		if name == "" || strings.HasSuffix(name, string(security.NoExtension)) || !security.BlessingPattern(name).IsValid() {
+			return fmt.Errorf("invalid blessing name %q", name)
+		}
+	}
+	var acl *access.AccessList
+	if len(in) > 0 {
+		acl = &access.AccessList{NotIn: notIn}
+		for _, p := range in {
+			pattern := security.BlessingPattern(p)
+			if !pattern.IsValid() {
+				return fmt.Errorf("invalid blessing pattern %q", p)
+			}
+			acl.In = append(acl.In, pattern)
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case tag == "":
+		a.acl = acl
+	case acl == nil:
+		delete(a.tags, tag)
+	default:
+		if a.tags == nil {
+			a.tags = map[access.Tag]*access.AccessList{}
+		}
+		a.tags[tag] = acl
+	}
+	return nil
+}
+
+func isTypicalTag(tag access.Tag) bool {
+	for _, t := range access.AllTypicalTags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// adminService implements the V23ServerProxyAdmin interface.
+type adminService struct {
+	delegate *delegate
+}
+
+func (a *adminService) ListSessions() (outSessions []v23serverproxy.SessionInfo, err error) {
+	infos := a.delegate.sessions.List()
+	outSessions = make([]v23serverproxy.SessionInfo, 0, len(infos))
+	for _, info := range infos {
+		outSessions = append(outSessions, v23serverproxy.SessionInfo{
+			Id:                info.ID,
+			Kind:              info.Kind,
+			Name:              info.Name,
+			InterfaceName:     info.Interface,
+			StartedUnixMicros: info.Started.UnixNano() / int64(time.Microsecond),
+		})
+	}
+	return outSessions, nil
+}
+
+func (a *adminService) ListExports() (outExports []v23serverproxy.ExportInfo, err error) {
+	list := a.delegate.exports.List()
+	outExports = make([]v23serverproxy.ExportInfo, 0, len(list))
+	for _, e := range list {
+		outExports = append(outExports, v23serverproxy.ExportInfo{
+			Name:          e.Name,
+			MojoUrl:       e.URL,
+			InterfaceName: e.Interface,
+			Methods:       append([]string{}, e.Methods...),
+		})
+	}
+	return outExports, nil
+}
+
+func (a *adminService) GetMethodStats() (outStats []v23serverproxy.ServerMethodStats, err error) {
+	snaps := a.delegate.metrics.Snapshot()
+	outStats = make([]v23serverproxy.ServerMethodStats, 0, len(snaps))
+	for _, snap := range snaps {
+		outStats = append(outStats, v23serverproxy.ServerMethodStats{
+			MojoUrl:         snap.Name,
+			InterfaceName:   snap.Interface,
+			Method:          snap.Method,
+			Calls:           snap.Calls,
+			Errors:          snap.Errors,
+			BytesIn:         snap.BytesIn,
+			BytesOut:        snap.BytesOut,
+			TranscodeMicros: int64(snap.Time[metrics.Transcode] / time.Microsecond),
+			AppMicros:       int64(snap.Time[metrics.App] / time.Microsecond),
+		})
+	}
+	return outStats, nil
+}
+
+func (a *adminService) SetLogVerbosity(inLevel int32) (outError *string, err error) {
+	if err := vlog.Log.Configure(vlog.Level(inLevel)); err != nil {
+		return errorString(err), nil
+	}
+	a.delegate.ctx.Infof("log verbosity set to %d", inLevel)
+	return nil, nil
+}
+
+func (a *adminService) UpdateAccessList(inInPatterns []string, inNotIn []string) (outError *string, err error) {
+	if err := a.delegate.auth.update("", inInPatterns, inNotIn); err != nil {
+		return errorString(err), nil
+	}
+	a.delegate.ctx.Infof("access list updated: in %v, not in %v", inInPatterns, inNotIn)
+	return nil, nil
+}
+
+func (a *adminService) UpdateTagAccessList(inTag string, inInPatterns []string, inNotIn []string) (outError *string, err error) {
+	if inTag == "" {
+		return errorString(fmt.Errorf("missing access tag")), nil
+	}
+	if err := a.delegate.auth.update(access.Tag(inTag), inInPatterns, inNotIn); err != nil {
+		return errorString(err), nil
+	}
+	a.delegate.ctx.Infof("access list for %s updated: in %v, not in %v", inTag, inInPatterns, inNotIn)
+	return nil, nil
+}
+
+func (a *adminService) Drain() (err error) {
+	a.delegate.ctx.Infof("draining")
+	a.delegate.gate.Drain()
+	return nil
+}
+
+func (a *adminService) Stop() (err error) {
+	a.delegate.ctx.Infof("stopping")
+	a.delegate.stopAdvertising()
+	a.delegate.stopServer()
+	return nil
+}
+
+func errorString(err error) *string {
+	s := err.Error()
+	return &s
+}
+
+// adminFactory serves V23ServerProxyAdmin requests. It is separate from the
+// delegate, which already serves V23ServerProxy through its Create method.
+type adminFactory struct {
+	delegate *delegate
+}
+
+func (f *adminFactory) Create(request v23serverproxy.V23ServerProxyAdmin_Request) {
+	delegate := f.delegate
+	stub := v23serverproxy.NewV23ServerProxyAdminStub(request, &adminService{delegate}, bindings.GetAsyncWaiter())
+	s, err := delegate.sessions.Add(session.Info{Kind: "admin"}, func() { stub.Close() })
+	if err != nil {
+		delegate.ctx.Errorf("rejecting V23ServerProxyAdmin connection: %v", err)
+		stub.Close()
+		return
+	}
+
+	go func() {
+		// Serve requests until the app closes its end of the pipe.
+		defer s.Close()
+		for {
+			if err := stub.ServeRequest(); err != nil {
+				connectionError, ok := err.(*bindings.ConnectionError)
+				if !ok || !connectionError.Closed() {
+					delegate.ctx.Errorf("%v", err)
+				}
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+func TestAuthorizeTagAccessList(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	principal := v23.GetPrincipal(ctx)
+	blessings := func(name string) security.Blessings {
+		b, err := principal.BlessSelf(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := security.AddToRoots(principal, b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	alice, bob := blessings("alice"), blessings("bob")
+
+	// The tags of a mojom method declared with [Access="Read"].
+	readTags, err := transcoder.MojomMethodTags(&mojom_types.DeclarationData{
+		Attributes: &[]mojom_types.Attribute{{
+			Key:   transcoder.AccessAttribute,
+			Value: &mojom_types.LiteralValueStringValue{"Read"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &aclAuthorizer{}
+	admin := &adminService{delegate: &delegate{ctx: ctx, auth: auth}}
+	authorize := func(b security.Blessings, tags []*vdl.Value) error {
+		return auth.Authorize(ctx, security.NewCall(&security.CallParams{
+			Timestamp:       time.Now(),
+			Method:          "Get",
+			MethodTags:      tags,
+			LocalPrincipal:  principal,
+			RemoteBlessings: b,
+		}))
+	}
+	check := func(name string, b security.Blessings, tags []*vdl.Value, allowed bool) {
+		if err := authorize(b, tags); (err == nil) != allowed {
+			t.Errorf("%s with tags %v: got error %v, want allowed=%v", name, tags, err, allowed)
+		}
+	}
+
+	if outError, err := admin.UpdateAccessList([]string{"alice"}, nil); outError != nil || err != nil {
+		t.Fatalf("UpdateAccessList: %v, %v", outError, err)
+	}
+	if outError, err := admin.UpdateTagAccessList("Read", []string{"bob"}, nil); outError != nil || err != nil {
+		t.Fatalf("UpdateTagAccessList: %v, %v", outError, err)
+	}
+	// Read methods are checked against the Read list, others against the
+	// default list.
+	check("alice", alice, nil, true)
+	check("bob", bob, nil, false)
+	check("alice", alice, readTags, false)
+	check("bob", bob, readTags, true)
+
+	// Without a Read list, Read methods use the default list.
+	if outError, err := admin.UpdateTagAccessList("Read", nil, nil); outError != nil || err != nil {
+		t.Fatalf("UpdateTagAccessList: %v, %v", outError, err)
+	}
+	check("alice", alice, readTags, true)
+	check("bob", bob, readTags, false)
+
+	// A not in list without in patterns is rejected rather than allowing
+	// everyone, as are invalid blessing names.
+	for _, notIn := range [][]string{{"bob"}, {"bob::x"}, {"bob:$"}, {""}} {
+		in := []string{"alice"}
+		if notIn[0] == "bob" {
+			in = nil
+		}
+		if outError, err := admin.UpdateAccessList(in, notIn); outError == nil || err != nil {
+			t.Errorf("UpdateAccessList(%v, %v): got %v, %v, want an error string", in, notIn, outError, err)
+		}
+		if outError, err := admin.UpdateTagAccessList("Read", in, notIn); outError == nil || err != nil {
+			t.Errorf("UpdateTagAccessList(Read, %v, %v): got %v, %v, want an error string", in, notIn, outError, err)
+		}
+	}
+	check("alice", alice, nil, true)
+	check("bob", bob, nil, false)
+
+	for _, tag := range []string{"", "Bogus"} {
+		if outError, err := admin.UpdateTagAccessList(tag, []string{"bob"}, nil); outError == nil || err != nil {
+			t.Errorf("UpdateTagAccessList(%q): got %v, %v, want an error string", tag, outError, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+
+	"v.io/x/mojo/discovery"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/ref/lib/discovery/factory"
+)
+
+// advertise controls whether exports are advertised through Vanadium
+// discovery, so that clients on the same network can find them.
+var advertise = flag.Bool("advertise", true, "advertise exported services through Vanadium discovery")
+
+// startAdvertising advertises the current exports. Failing to set up
+// discovery is not fatal, since the proxy can still be reached by name.
+func (delegate *delegate) startAdvertising() {
+	if !*advertise {
+		return
+	}
+	d, err := factory.New()
+	if err != nil {
+		delegate.ctx.Errorf("discovery is unavailable, not advertising exports: %v", err)
+		return
+	}
+	delegate.discovery = d
+	delegate.advertiser = discovery.NewAdvertiser(delegate.ctx, d, delegate.endpointNames)
+	for _, e := range delegate.exports.List() {
+		delegate.advertiseExport(e)
+	}
+}
+
+func (delegate *delegate) endpointNames() []string {
+	var names []string
+	for _, ep := range delegate.v23Server.Status().Endpoints {
+		names = append(names, ep.Name())
+	}
+	return names
+}
+
+func (delegate *delegate) advertiseExport(e exports.Export) {
+	if delegate.advertiser == nil {
+		return
+	}
+	switch err := delegate.advertiser.Advertise(e); err {
+	case nil, discovery.ErrStopped:
+		// Exports added after Stop are not advertised.
+	default:
+		delegate.ctx.Errorf("%v", err)
+	}
+}
+
+func (delegate *delegate) unadvertiseExport(name string) {
+	if delegate.advertiser != nil {
+		delegate.advertiser.Stop(name)
+	}
+}
+
+// stopAdvertising withdraws every advertisement.
+func (delegate *delegate) stopAdvertising() {
+	if delegate.advertiser != nil {
+		delegate.advertiser.StopAll()
+	}
+}
@@ -5,193 +5,52 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
-	"strings"
 
 	"mojo/public/go/application"
 	"mojo/public/go/bindings"
 	"mojo/public/go/system"
-	"mojo/public/interfaces/bindings/mojom_types"
-	"mojo/public/interfaces/bindings/service_describer"
 
 	"mojom/v23serverproxy"
 
 	"v.io/v23"
 	"v.io/v23/context"
+	vdiscovery "v.io/v23/discovery"
 	"v.io/v23/rpc"
-	"v.io/v23/security"
-	"v.io/v23/vdl"
-	"v.io/v23/vdlroot/signature"
-	"v.io/v23/vom"
+	"v.io/x/mojo/discovery"
+	"v.io/x/mojo/internal/serverproxy"
+	"v.io/x/mojo/proxy/capture"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/flags"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
 	"v.io/x/mojo/proxy/util"
-	"v.io/x/mojo/transcoder"
 	"v.io/x/ref/runtime/factories/roaming"
 )
 
 //#include "mojo/public/c/system/handle.h"
 import "C"
 
-// As long as fakeService meets the Invoker interface, it is allowed to pass as
-// a universal v23 service.
-// See the function objectToInvoker in v.io/x/ref/runtime/internal/rpc/server.go
-type fakeService struct {
-	appctx application.Context
-	suffix string
-	router *bindings.Router
-	ids    bindings.Counter
-}
-
-// Prepare is used by the Fake Service to prepare the placeholders for the
-// input data.
-func (fs fakeService) Prepare(ctx *context.T, method string, numArgs int) (argptrs []interface{}, tags []*vdl.Value, _ error) {
-	inargs := make([]*vom.RawBytes, numArgs)
-	inptrs := make([]interface{}, len(inargs))
-	for i := range inargs {
-		inptrs[i] = &inargs[i]
-	}
-	return inptrs, nil, nil
-}
-
-// Wraps the interface request and the name of the requested mojo service.
-type v23ServiceRequest struct {
-	request bindings.InterfaceRequest
-	name    string
-}
-
-func (v *v23ServiceRequest) Name() string {
-	return v.name
-}
-
-func (v *v23ServiceRequest) ServiceDescription() service_describer.ServiceDescription {
-	panic("not supported")
-}
-
-func (v *v23ServiceRequest) PassMessagePipe() system.MessagePipeHandle {
-	return v.request.PassMessagePipe()
-}
-
-// Invoke calls the mojom service based on the suffix and converts the mojom
-// results (a struct) to Vanadium results (a slice of *vom.RawBytes).
-// Note: The argptrs from Prepare are reused here. The vom bytes should have
-// been decoded into these argptrs, so there are actual values inside now.
-func (fs fakeService) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) (results []interface{}, _ error) {
-	// fs.suffix consists of the mojo url and the application/interface name.
-	// The last part should be the name; everything else is the url.
-	parts := strings.Split(fs.suffix, "/")
-	mojourl := strings.Join(parts[:len(parts)-1], "/") // e.g., mojo:go_remote_echo_server. May be defined in a BUILD.gn file.
-	mojoname := parts[len(parts)-1]                    // e.g., mojo::examples::RemoteEcho. Defined from the interface + module.
-
-	// Create the generic message pipe. r is a bindings.InterfaceRequest, and
-	// p is a bindings.InterfacePointer.
-	r, p := bindings.CreateMessagePipeForMojoInterface()
-	v := v23ServiceRequest{
-		request: r,
-		name:    mojoname,
-	} // v is an application.ServiceRequest with mojoname
+// maxSessions bounds the number of mojo pipes that the proxy holds open at
+// once. Each in-flight Vanadium call holds one pipe to the mojo app it calls.
+var maxSessions = flag.Int("max-sessions", 1024, "maximum number of open sessions; 0 means no limit")
 
-	// Connect to the mojourl.
-	fs.appctx.ConnectToApplication(mojourl).ConnectToService(&v)
+// exportFlag lists the mojo services that are reachable when the proxy starts.
+// Mojo apps can add more through V23ServerProxy.Export. Callers address an
+// export either by its name, e.g., <endpoint>/echo, or by its escaped address,
+// i.e., <endpoint>/<escaped url>/<escaped interface>.
+var exportFlag = flag.String("exports", "", "comma-separated list of <name>=<escaped url>/<escaped interface>[#<method>...]")
 
-	// Then assign a new router the FakeService.
-	// This will never conflict because each FakeService is only invoked once.
-	fs.router = bindings.NewRouter(p.PassMessagePipe(), bindings.GetAsyncWaiter())
-	defer fs.Close_Proxy()
+// aliases maps short names to mojo services, so that callers can use, e.g.,
+// <endpoint>/echo instead of <endpoint>/<escaped url>/<escaped interface>.
+// Each alias exports every method of its service, like an -exports entry
+// without methods.
+var aliases = flag.String("aliases", "", "comma-separated list of <alias>=<escaped url>/<escaped interface>")
 
-	ctx.Infof("Fake Service Invoke (Remote Signature: %q -- %q)", mojourl, mojoname)
-
-	// Vanadium relies on type information, so we will retrieve that first.
-	mojomInterface, desc, err := fs.callRemoteSignature(mojourl, mojoname)
-	if err != nil {
-		return nil, err
-	}
-
-	ctx.Infof("Fake Service Invoke Signature %v", mojomInterface)
-	ctx.Infof("Fake Service Invoke (Remote Method: %v)", method)
-
-	// With the type information, we can make the method call to the remote interface.
-	methodResults, err := fs.callRemoteMethod(ctx, method, mojomInterface, desc, argptrs)
-	if err != nil {
-		ctx.Errorf("Method called failed: %v", err)
-		return nil, err
-	}
-
-	ctx.Infof("Fake Service Invoke Results %v", methodResults)
-
-	// Convert methodResult to results.
-	results = make([]interface{}, len(methodResults))
-	for i := range methodResults {
-		results[i] = &methodResults[i]
-	}
-	return results, nil
-}
-
-func (fs fakeService) Close_Proxy() {
-	fs.router.Close()
-}
-
-// callRemoteSignature obtains type and header information from the remote
-// mojo service. Remote mojo interfaces all define a signature method.
-func (fs fakeService) callRemoteSignature(mojourl string, mojoname string) (mojomInterface mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType, err error) {
-	// TODO(afandria): The service_describer mojom file defines the constant, but
-	// it is not actually present in the generated code:
-	// https://github.com/domokit/mojo/issues/469
-	// serviceDescriberInterfaceName := "_ServiceDescriber"
-
-	r, p := service_describer.CreateMessagePipeForServiceDescriber()
-	fs.appctx.ConnectToApplication(mojourl).ConnectToService(&r)
-	sDescriber := service_describer.NewServiceDescriberProxy(p, bindings.GetAsyncWaiter())
-	defer sDescriber.Close_Proxy()
-
-	r2, p2 := service_describer.CreateMessagePipeForServiceDescription()
-	err = sDescriber.DescribeService(mojoname, r2)
-	if err != nil {
-		return
-	}
-	sDescription := service_describer.NewServiceDescriptionProxy(p2, bindings.GetAsyncWaiter())
-	defer sDescription.Close_Proxy()
-
-	mojomInterface, err = sDescription.GetTopLevelInterface()
-	if err != nil {
-		return
-	}
-	descPtr, err := sDescription.GetAllTypeDefinitions()
-	if err != nil {
-		return
-	}
-	return mojomInterface, *descPtr, nil
-}
-
-// A helper function that sends a remote message that expects a response.
-func (fs fakeService) callRemoteWithResponse(ctx *context.T, message *bindings.Message) (outMessage *bindings.Message, err error) {
-	ctx.Infof("callRemoteGeneric: Send message along the router")
-
-	readResult := <-fs.router.AcceptWithResponse(message)
-	if err = readResult.Error; err != nil {
-		return
-	}
-
-	ctx.Infof("callRemoteGeneric: Audit response message header flag")
-	// The message flag we receive back must be a bindings.MessageIsResponseFlag
-	if readResult.Message.Header.Flags != bindings.MessageIsResponseFlag {
-		err = &bindings.ValidationError{bindings.MessageHeaderInvalidFlags,
-			fmt.Sprintf("invalid message header flag: %v", readResult.Message.Header.Flags),
-		}
-		return
-	}
-
-	ctx.Infof("callRemoteGeneric: Audit response message header type")
-	// While the mojo service we called into will return a header whose
-	// type must match our outgoing one.
-	if got, want := readResult.Message.Header.Type, message.Header.Type; got != want {
-		err = &bindings.ValidationError{bindings.MessageHeaderUnknownMethod,
-			fmt.Sprintf("invalid method in response: expected %v, got %v", want, got),
-		}
-		return
-	}
-
-	return readResult.Message, nil
-}
+// adminURL names the only mojo app that is offered V23ServerProxyAdmin, which
+// can drain and stop the proxy and replace its access lists.
+var adminURL = flag.String("admin-url", "", "url of the mojo app allowed to use V23ServerProxyAdmin; if empty, no app is")
 
 type mojoService struct {
 	delegate *delegate
@@ -206,129 +65,46 @@ func (r *mojoService) Endpoints() (endpoints []string, err error) {
 	return endpoints, nil
 }
 
-// callRemoteMethod calls the method remotely in a generic way.
-// Produces []*vom.RawBytes at the end for the invoker to return.
-func (fs fakeService) callRemoteMethod(ctx *context.T, method string, mi mojom_types.MojomInterface, desc map[string]mojom_types.UserDefinedType, argptrs []interface{}) ([]*vom.RawBytes, error) {
-	// We need to parse the signature result to get the method relevant info out.
-	found := false
-	var ordinal uint32
-	for ord, mm := range mi.Methods {
-		if *mm.DeclData.ShortName == method {
-			ordinal = ord
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return nil, fmt.Errorf("callRemoteMethod: method %s does not exist", method)
-	}
-
-	mm := mi.Methods[ordinal]
-
-	// A void function must have request id of 0, whereas one with response params
-	// should  have a unique request id.
-	header := bindings.MessageHeader{
-		Type:      ordinal,
-		Flags:     bindings.MessageExpectsResponseFlag,
-		RequestId: fs.ids.Count(),
-	}
-
-	// Now produce the *bindings.Message that we will send to the other side.
-	inType, err := transcoder.MojomStructToVDLType(mm.Parameters, desc)
-	if err != nil {
-		return nil, err
-	}
-	message, err := encodeMessageFromVom(header, argptrs, inType)
-	if err != nil {
-		return nil, err
-	}
-
-	// Otherwise, make a generic call with the message.
-	outMessage, err := fs.callRemoteWithResponse(ctx, message)
-	if err != nil {
-		return nil, err
-	}
-
-	// Decode the *vom.RawBytes from the mojom bytes and mojom type.
-	outType, err := transcoder.MojomStructToVDLType(*mm.ResponseParams, desc)
-	if err != nil {
-		return nil, err
-	}
-	target := util.StructSplitTarget()
-	if err := transcoder.FromMojo(target, outMessage.Payload, outType); err != nil {
-		return nil, fmt.Errorf("transcoder.FromMojo failed: %v", err)
-	}
-	return target.Fields(), nil
-}
-
-func encodeMessageFromVom(header bindings.MessageHeader, argptrs []interface{}, t *vdl.Type) (*bindings.Message, error) {
-	// Convert argptrs into their true form: []*vom.RawBytes
-	inargs := make([]*vom.RawBytes, len(argptrs))
-	for i := range argptrs {
-		inargs[i] = *argptrs[i].(**vom.RawBytes)
+func (r *mojoService) Export(inName string, inMojoUrl string, inInterfaceName string, inMethods *[]string) (outError *string, err error) {
+	e := exports.Export{
+		Name:    inName,
+		Address: util.Address{URL: inMojoUrl, Interface: inInterfaceName},
 	}
-
-	encoder := bindings.NewEncoder()
-	if err := header.Encode(encoder); err != nil {
-		return nil, err
+	if inMethods != nil {
+		e.Methods = *inMethods
 	}
-	if bytes, handles, err := encoder.Data(); err != nil {
-		return nil, err
-	} else {
-		target := transcoder.ToMojomTarget()
-		if err := util.JoinRawBytesAsStruct(target, t, inargs); err != nil {
-			return nil, err
-		}
-		moreBytes := target.Bytes()
-		// Append the encoded "payload" to the end of the slice.
-		bytes = append(bytes, moreBytes...)
-
-		return &bindings.Message{
-			Header:  header,
-			Bytes:   bytes,
-			Handles: handles,
-			Payload: moreBytes,
-		}, nil
+	if err := r.delegate.exports.Add(e); err != nil {
+		return errorString(err), nil
 	}
-}
-
-// The fake service has no signature.
-func (fs fakeService) Signature(ctx *context.T, call rpc.ServerCall) ([]signature.Interface, error) {
-	ctx.Infof("Fake Service Signature???")
+	r.delegate.ctx.Infof("exported %s.%s as %q", inMojoUrl, inInterfaceName, inName)
+	r.delegate.advertiseExport(e)
 	return nil, nil
 }
 
-// The fake service knows nothing about method signatures.
-func (fs fakeService) MethodSignature(ctx *context.T, call rpc.ServerCall, method string) (signature.Method, error) {
-	ctx.Infof("Fake Service Method Signature???")
-	return signature.Method{}, nil
-}
-
-// The fake service will never need to glob.
-func (fs fakeService) Globber() *rpc.GlobState {
-	log.Printf("Fake Service Globber???")
-	return nil
-}
-
-type dispatcher struct {
-	appctx application.Context
-}
-
-func (v23pd *dispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
-	ctx.Infof("dispatcher.Lookup for suffix: %s", suffix)
-	return fakeService{
-		appctx: v23pd.appctx,
-		suffix: suffix,
-		ids:    bindings.NewCounter(),
-	}, security.AllowEveryone(), nil
+func (r *mojoService) Unexport(inName string) (outFound bool, err error) {
+	outFound = r.delegate.exports.Remove(inName)
+	if outFound {
+		r.delegate.dispatcher.Forget(inName)
+		r.delegate.ctx.Infof("unexported %q", inName)
+		r.delegate.unadvertiseExport(inName)
+	}
+	return outFound, nil
 }
 
 type delegate struct {
-	ctx       *context.T
-	shutdown  v23.Shutdown
-	stubs     []*bindings.Stub
-	v23Server rpc.Server
+	ctx        *context.T
+	shutdown   v23.Shutdown
+	sessions   *session.Registry
+	metrics    *metrics.Recorder
+	exports    *exports.Registry
+	gate       *serverproxy.Gate
+	auth       *aclAuthorizer
+	dispatcher *serverproxy.Dispatcher
+	v23Server  rpc.Server
+	stopServer func()
+	discovery  vdiscovery.T
+	advertiser *discovery.Advertiser
+	capture    *capture.Writer
 }
 
 func (delegate *delegate) Initialize(context application.Context) {
@@ -339,47 +115,108 @@ func (delegate *delegate) Initialize(context application.Context) {
 	ctx, shutdown := v23.Init()
 	delegate.ctx = ctx
 	delegate.shutdown = shutdown
-	ctx.Infof("delegate.Initialize...")
+	delegate.sessions = session.NewRegistry(*maxSessions)
+	delegate.metrics = metrics.NewRecorder("v23proxy/server", metrics.Transcode, metrics.App)
+	delegate.gate = &serverproxy.Gate{}
+	initial, err := exports.Parse(*exportFlag)
+	if err != nil {
+		ctx.Fatal("Invalid -exports: ", err)
+	}
+	aliased, err := util.ParseAliases(*aliases)
+	if err != nil {
+		ctx.Fatal("Invalid -aliases: ", err)
+	}
+	if delegate.exports, err = exports.NewRegistry(append(initial, exports.FromAliases(aliased)...)...); err != nil {
+		ctx.Fatal("Invalid -exports or -aliases: ", err)
+	}
+	delegate.auth = &aclAuthorizer{}
+	if *flags.Capture != "" {
+		if delegate.capture, err = capture.Create(*flags.Capture); err != nil {
+			ctx.Fatal("Invalid -capture: ", err)
+		}
+	}
+	ctx.VI(1).Infof("delegate.Initialize...")
 
 	// TODO(alexfandrianto): Does Mojo stop us from creating too many v23proxy?
 	// Is it 1 per shell? Ideally, each device will only serve 1 of these v23proxy,
 	// but it is not problematic to have extra.
-	_, s, err := v23.WithNewDispatchingServer(ctx, "", &dispatcher{
-		appctx: context,
-	})
-	if err != nil {
+	if err := delegate.listen(context); err != nil {
 		ctx.Fatal("Error serving service: ", err)
 	}
+	fmt.Println("Listening at:", delegate.v23Server.Status().Endpoints[0].Name())
+	delegate.startAdvertising()
+}
+
+// listen starts the Vanadium server that dispatches calls to mojo apps. The
+// server stops listening when delegate.stopServer is called.
+func (delegate *delegate) listen(appctx application.Context) error {
+	ctx, cancel := context.WithCancel(delegate.ctx)
+	d := &serverproxy.Dispatcher{
+		Apps:     serverproxy.AppContext(appctx),
+		Sessions: delegate.sessions,
+		Metrics:  delegate.metrics,
+		Gate:     delegate.gate,
+		Auth:     delegate.auth,
+		Exports:  delegate.exports,
+		LogArgs:  *flags.LogArgs,
+		Capture:  delegate.capture,
+	}
+	_, s, err := v23.WithNewDispatchingServer(ctx, "", d)
+	if err != nil {
+		cancel()
+		return err
+	}
+	delegate.dispatcher = d
 	delegate.v23Server = s
-	fmt.Println("Listening at:", s.Status().Endpoints[0].Name())
+	delegate.stopServer = cancel
+	return nil
 }
 
 func (delegate *delegate) Create(request v23serverproxy.V23ServerProxy_Request) {
 	svc := &mojoService{delegate: delegate}
 	v23Stub := v23serverproxy.NewV23ServerProxyStub(request, svc, bindings.GetAsyncWaiter())
-	delegate.stubs = append(delegate.stubs, v23Stub)
+	s, err := delegate.sessions.Add(session.Info{Kind: "control"}, func() { v23Stub.Close() })
+	if err != nil {
+		delegate.ctx.Errorf("rejecting V23ServerProxy connection: %v", err)
+		v23Stub.Close()
+		return
+	}
 
 	go func() {
-		// Read header message
-		if err := v23Stub.ServeRequest(); err != nil {
-			connectionError, ok := err.(*bindings.ConnectionError)
-			if !ok || !connectionError.Closed() {
-				delegate.ctx.Errorf("%v", err)
+		// Serve requests until the app closes its end of the pipe.
+		defer s.Close()
+		for {
+			if err := v23Stub.ServeRequest(); err != nil {
+				connectionError, ok := err.(*bindings.ConnectionError)
+				if !ok || !connectionError.Closed() {
+					delegate.ctx.Errorf("%v", err)
+				}
+				return
 			}
-			return
 		}
 	}()
 }
 
 func (delegate *delegate) AcceptConnection(connection *application.Connection) {
-	delegate.ctx.Infof("delegate.AcceptConnection...")
-	connection.ProvideServices(&v23serverproxy.V23ServerProxy_ServiceFactory{delegate})
+	delegate.ctx.VI(1).Infof("delegate.AcceptConnection...")
+	services := []application.ServiceFactory{
+		&v23serverproxy.V23ServerProxy_ServiceFactory{delegate},
+	}
+	if *adminURL != "" && connection.RequestorURL() == *adminURL {
+		services = append(services, &v23serverproxy.V23ServerProxyAdmin_ServiceFactory{&adminFactory{delegate}})
+	}
+	connection.ProvideServices(services...)
 }
 
 func (delegate *delegate) Quit() {
-	delegate.ctx.Infof("delegate.Quit...")
-	for _, stub := range delegate.stubs {
-		stub.Close()
+	delegate.ctx.VI(1).Infof("delegate.Quit...")
+	delegate.stopAdvertising()
+	if delegate.discovery != nil {
+		delegate.discovery.Close()
+	}
+	delegate.sessions.CloseAll()
+	if delegate.capture != nil {
+		delegate.capture.Close()
 	}
 	delegate.shutdown()
 }
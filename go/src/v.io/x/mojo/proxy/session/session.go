@@ -0,0 +1,146 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package session keeps track of the mojo pipes that a v23proxy is serving so
+// that they can be listed, bounded and closed when the proxy quits.
+package session
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManySessions is returned by Add when the registry is full.
+	ErrTooManySessions = errors.New("session: too many open sessions")
+	// ErrClosed is returned by Add after CloseAll has been called.
+	ErrClosed = errors.New("session: registry is closed")
+)
+
+// Info describes an open session.
+type Info struct {
+	ID        uint64
+	Kind      string // e.g., "client", "server" or "control"
+	Name      string // The v23 name or mojo url on the other end.
+	Interface string // The mojom interface being served, if known.
+	Started   time.Time
+}
+
+// Registry is a concurrency-safe set of open sessions.
+type Registry struct {
+	max int
+
+	mu       sync.Mutex
+	nextID   uint64
+	sessions map[uint64]*Session
+	closed   bool
+}
+
+// NewRegistry returns an empty registry that holds at most max sessions.
+// A max of zero or less means that there is no limit.
+func NewRegistry(max int) *Registry {
+	return &Registry{
+		max:      max,
+		sessions: map[uint64]*Session{},
+	}
+}
+
+// Session is a single entry in a Registry.
+type Session struct {
+	registry *Registry
+	info     Info
+	close    func()
+	once     sync.Once
+}
+
+// Add registers a new session described by info. close is called at most
+// once, either from Session.Close or from Registry.CloseAll, and should
+// release every resource held by the session.
+func (r *Registry) Add(info Info, close func()) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrClosed
+	}
+	if r.max > 0 && len(r.sessions) >= r.max {
+		return nil, ErrTooManySessions
+	}
+	r.nextID++
+	info.ID = r.nextID
+	if info.Started.IsZero() {
+		info.Started = time.Now()
+	}
+	s := &Session{
+		registry: r,
+		info:     info,
+		close:    close,
+	}
+	r.sessions[info.ID] = s
+	return s, nil
+}
+
+// List returns the open sessions, ordered by ID.
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]Info, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		infos = append(infos, s.info)
+	}
+	sort.Sort(byID(infos))
+	return infos
+}
+
+// Len returns the number of open sessions.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// CloseAll closes every open session. Subsequent calls to Add fail with
+// ErrClosed.
+func (r *Registry) CloseAll() {
+	r.mu.Lock()
+	r.closed = true
+	sessions := r.sessions
+	r.sessions = map[uint64]*Session{}
+	r.mu.Unlock()
+
+	for _, s := range sessions {
+		s.once.Do(s.close)
+	}
+}
+
+func (r *Registry) remove(id uint64) {
+	r.mu.Lock()
+	delete(r.sessions, id)
+	r.mu.Unlock()
+}
+
+// Info returns the description of the session.
+func (s *Session) Info() Info {
+	return s.info
+}
+
+// Remove drops the session from its registry without closing it. It is
+// meant for sessions whose resources were already released, e.g., because
+// the other end of the pipe disconnected.
+func (s *Session) Remove() {
+	s.registry.remove(s.info.ID)
+}
+
+// Close releases the session's resources and removes it from its registry.
+func (s *Session) Close() {
+	s.Remove()
+	s.once.Do(s.close)
+}
+
+type byID []Info
+
+func (b byID) Len() int           { return len(b) }
+func (b byID) Less(i, j int) bool { return b[i].ID < b[j].ID }
+func (b byID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,87 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package session_test
+
+import (
+	"sync"
+	"testing"
+
+	"v.io/x/mojo/proxy/session"
+)
+
+func TestAddRemove(t *testing.T) {
+	r := session.NewRegistry(0)
+	closed := 0
+	a, err := r.Add(session.Info{Kind: "client", Name: "a"}, func() { closed++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Add(session.Info{Kind: "server", Name: "b"}, func() { closed++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Len(), 2; got != want {
+		t.Errorf("got %d sessions, want %d", got, want)
+	}
+	if got := r.List(); got[0].Name != "a" || got[1].Name != "b" {
+		t.Errorf("sessions not listed in order: %v", got)
+	}
+
+	a.Remove()
+	if got, want := closed, 0; got != want {
+		t.Errorf("Remove closed %d sessions, want %d", got, want)
+	}
+	b.Close()
+	b.Close()
+	if got, want := closed, 1; got != want {
+		t.Errorf("Close closed %d sessions, want %d", got, want)
+	}
+	if got, want := r.Len(), 0; got != want {
+		t.Errorf("got %d sessions, want %d", got, want)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	r := session.NewRegistry(1)
+	s, err := r.Add(session.Info{}, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Add(session.Info{}, func() {}); err != session.ErrTooManySessions {
+		t.Errorf("got error %v, want %v", err, session.ErrTooManySessions)
+	}
+	s.Remove()
+	if _, err := r.Add(session.Info{}, func() {}); err != nil {
+		t.Errorf("unexpected error after removing a session: %v", err)
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+	r := session.NewRegistry(0)
+	var mu sync.Mutex
+	closed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Add(session.Info{}, func() {
+				mu.Lock()
+				closed++
+				mu.Unlock()
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	r.CloseAll()
+	if got, want := closed, 10; got != want {
+		t.Errorf("closed %d sessions, want %d", got, want)
+	}
+	if _, err := r.Add(session.Info{}, func() {}); err != session.ErrClosed {
+		t.Errorf("got error %v, want %v", err, session.ErrClosed)
+	}
+}
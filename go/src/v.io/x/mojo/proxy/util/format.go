@@ -0,0 +1,216 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/context"
+	"v.io/v23/vdl"
+	"v.io/v23/vom"
+)
+
+// SensitiveAttribute is the mojom attribute that marks a struct or union
+// field whose value must not be logged, e.g.,
+//
+//	struct Credentials {
+//	  string user;
+//	  [Sensitive] string password;
+//	};
+const SensitiveAttribute = "Sensitive"
+
+// Redacted replaces the values of sensitive fields in formatted arguments.
+const Redacted = "<redacted>"
+
+// Attribute returns the value of the attribute named key in decl, if present.
+func Attribute(decl *mojom_types.DeclarationData, key string) (mojom_types.LiteralValue, bool) {
+	if decl == nil || decl.Attributes == nil {
+		return nil, false
+	}
+	for _, attr := range *decl.Attributes {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return nil, false
+}
+
+// IsSensitive returns true if decl carries the Sensitive attribute. An
+// explicit [Sensitive=false] is honored.
+func IsSensitive(decl *mojom_types.DeclarationData) bool {
+	value, ok := Attribute(decl, SensitiveAttribute)
+	if !ok {
+		return false
+	}
+	if b, ok := value.(*mojom_types.LiteralValueBoolValue); ok {
+		return b.Value
+	}
+	return true
+}
+
+// FormatArgs returns a human-readable form of the arguments of a call whose
+// parameters are described by params, e.g., `text="hello", count=2`. The
+// values of fields marked Sensitive are replaced by Redacted, at any depth.
+func FormatArgs(params mojom_types.MojomStruct, desc map[string]mojom_types.UserDefinedType, args []*vom.RawBytes) (string, error) {
+	if len(args) != len(params.Fields) {
+		return "", fmt.Errorf("received %d arguments, but the parameters have %d fields", len(args), len(params.Fields))
+	}
+	var buf bytes.Buffer
+	for i, field := range params.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(fieldName(field.DeclData))
+		buf.WriteString("=")
+		if IsSensitive(field.DeclData) {
+			buf.WriteString(Redacted)
+			continue
+		}
+		var v *vdl.Value
+		if err := args[i].ToValue(&v); err != nil {
+			return "", err
+		}
+		formatValue(&buf, v, field.Type, desc)
+	}
+	return buf.String(), nil
+}
+
+// LogArgs logs the arguments or results of a call to name.method, formatted
+// by FormatArgs. what tells which they are, e.g., "call" or "return".
+func LogArgs(ctx *context.T, what, name, method string, params mojom_types.MojomStruct, desc map[string]mojom_types.UserDefinedType, args []*vom.RawBytes) {
+	formatted, err := FormatArgs(params, desc, args)
+	if err != nil {
+		formatted = fmt.Sprintf("<%v>", err)
+	}
+	ctx.Infof("%s %s.%s(%s)", what, name, method, formatted)
+}
+
+func fieldName(decl *mojom_types.DeclarationData) string {
+	if decl == nil || decl.ShortName == nil {
+		return "?"
+	}
+	return *decl.ShortName
+}
+
+// formatValue writes v, whose mojom type is mt, to buf.
+func formatValue(buf *bytes.Buffer, v *vdl.Value, mt mojom_types.Type, desc map[string]mojom_types.UserDefinedType) {
+	if v == nil {
+		buf.WriteString("nil")
+		return
+	}
+	if v.Kind() == vdl.Optional {
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		v = v.Elem()
+	}
+	switch mt := mt.(type) {
+	case *mojom_types.TypeArrayType:
+		if v.Type().IsBytes() {
+			fmt.Fprintf(buf, "0x%x", v.Bytes())
+			return
+		}
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			formatValue(buf, v.Index(i), mt.Value.ElementType, desc)
+		}
+		buf.WriteString("]")
+		return
+	case *mojom_types.TypeMapType:
+		// Sort the entries so that the output is stable.
+		entries := make([]string, 0, v.Len())
+		for _, key := range v.Keys() {
+			var entry bytes.Buffer
+			formatValue(&entry, key, mt.Value.KeyType, desc)
+			entry.WriteString(": ")
+			formatValue(&entry, v.MapIndex(key), mt.Value.ValueType, desc)
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+		buf.WriteString("{")
+		for i, entry := range entries {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(entry)
+		}
+		buf.WriteString("}")
+		return
+	case *mojom_types.TypeTypeReference:
+		switch udt := desc[*mt.Value.TypeKey].(type) {
+		case *mojom_types.UserDefinedTypeStructType:
+			buf.WriteString("{")
+			for i, field := range udt.Value.Fields {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				buf.WriteString(fieldName(field.DeclData))
+				buf.WriteString(": ")
+				if IsSensitive(field.DeclData) {
+					buf.WriteString(Redacted)
+					continue
+				}
+				formatValue(buf, v.StructField(i), field.Type, desc)
+			}
+			buf.WriteString("}")
+			return
+		case *mojom_types.UserDefinedTypeUnionType:
+			index, fv := v.UnionField()
+			field := udt.Value.Fields[index]
+			buf.WriteString("{")
+			buf.WriteString(fieldName(field.DeclData))
+			buf.WriteString(": ")
+			if IsSensitive(field.DeclData) {
+				buf.WriteString(Redacted)
+			} else {
+				formatValue(buf, fv, field.Type, desc)
+			}
+			buf.WriteString("}")
+			return
+		}
+	}
+	formatScalar(buf, v)
+}
+
+func formatScalar(buf *bytes.Buffer, v *vdl.Value) {
+	switch v.Kind() {
+	case vdl.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case vdl.Float32, vdl.Float64:
+		buf.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case vdl.String:
+		buf.WriteString(strconv.Quote(v.RawString()))
+	case vdl.Enum:
+		buf.WriteString(v.EnumLabel())
+	default:
+		buf.WriteString(v.String())
+	}
+}
+
+// MatchInterface returns true if iface appears in list, a comma-separated list
+// of mojom interface names as given to the -log-args flag of the proxies. The
+// name "*" matches every interface.
+func MatchInterface(list, iface string) bool {
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "*" || name == iface {
+			return true
+		}
+	}
+	return false
+}
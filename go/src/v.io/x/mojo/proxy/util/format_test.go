@@ -0,0 +1,135 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util_test
+
+import (
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vom"
+	"v.io/x/mojo/proxy/util"
+)
+
+type Credentials struct {
+	User     string
+	Password string
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func field(name string, t mojom_types.Type, attrs ...mojom_types.Attribute) mojom_types.StructField {
+	decl := &mojom_types.DeclarationData{ShortName: strPtr(name)}
+	if len(attrs) > 0 {
+		decl.Attributes = &attrs
+	}
+	return mojom_types.StructField{DeclData: decl, Type: t}
+}
+
+var sensitive = mojom_types.Attribute{Key: util.SensitiveAttribute, Value: &mojom_types.LiteralValueBoolValue{true}}
+
+func TestFormatArgs(t *testing.T) {
+	stringType := &mojom_types.TypeStringType{mojom_types.StringType{false}}
+	int32Type := &mojom_types.TypeSimpleType{mojom_types.SimpleType_Int32}
+	desc := map[string]mojom_types.UserDefinedType{
+		"TYPE_KEY:Credentials": &mojom_types.UserDefinedTypeStructType{
+			mojom_types.MojomStruct{
+				DeclData: &mojom_types.DeclarationData{
+					ShortName:      strPtr("Credentials"),
+					FullIdentifier: strPtr("Credentials"),
+				},
+				Fields: []mojom_types.StructField{
+					field("user", stringType),
+					field("password", stringType, sensitive),
+				},
+			},
+		},
+	}
+	credentialsType := &mojom_types.TypeTypeReference{mojom_types.TypeReference{TypeKey: strPtr("TYPE_KEY:Credentials")}}
+
+	tests := []struct {
+		params mojom_types.MojomStruct
+		args   []*vom.RawBytes
+		want   string
+	}{
+		{
+			mojom_types.MojomStruct{Fields: []mojom_types.StructField{
+				field("text", stringType),
+				field("count", int32Type),
+			}},
+			[]*vom.RawBytes{vom.RawBytesOf("hello"), vom.RawBytesOf(int32(2))},
+			`text="hello", count=2`,
+		},
+		{
+			mojom_types.MojomStruct{Fields: []mojom_types.StructField{
+				field("token", stringType, sensitive),
+			}},
+			[]*vom.RawBytes{vom.RawBytesOf("secret")},
+			`token=<redacted>`,
+		},
+		{
+			mojom_types.MojomStruct{Fields: []mojom_types.StructField{
+				field("creds", credentialsType),
+			}},
+			[]*vom.RawBytes{vom.RawBytesOf(Credentials{"alice", "secret"})},
+			`creds={user: "alice", password: <redacted>}`,
+		},
+		{
+			mojom_types.MojomStruct{Fields: []mojom_types.StructField{
+				field("all", &mojom_types.TypeArrayType{mojom_types.ArrayType{false, -1, credentialsType}}),
+			}},
+			[]*vom.RawBytes{vom.RawBytesOf([]Credentials{{"alice", "a"}, {"bob", "b"}})},
+			`all=[{user: "alice", password: <redacted>}, {user: "bob", password: <redacted>}]`,
+		},
+	}
+	for _, test := range tests {
+		got, err := util.FormatArgs(test.params, desc, test.args)
+		if err != nil {
+			t.Errorf("FormatArgs(%v) failed: %v", test.want, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	notSensitive := mojom_types.Attribute{Key: util.SensitiveAttribute, Value: &mojom_types.LiteralValueBoolValue{false}}
+	tests := []struct {
+		attrs []mojom_types.Attribute
+		want  bool
+	}{
+		{nil, false},
+		{[]mojom_types.Attribute{sensitive}, true},
+		{[]mojom_types.Attribute{notSensitive}, false},
+	}
+	for _, test := range tests {
+		decl := field("f", nil, test.attrs...).DeclData
+		if got := util.IsSensitive(decl); got != test.want {
+			t.Errorf("IsSensitive(%v) = %v, want %v", test.attrs, got, test.want)
+		}
+	}
+}
+
+func TestMatchInterface(t *testing.T) {
+	tests := []struct {
+		list, iface string
+		want        bool
+	}{
+		{"", "mojo::examples::RemoteEcho", false},
+		{"mojo::examples::RemoteEcho", "mojo::examples::RemoteEcho", true},
+		{"mojo::examples::Fortune, mojo::examples::RemoteEcho", "mojo::examples::RemoteEcho", true},
+		{"mojo::examples::Fortune", "mojo::examples::RemoteEcho", false},
+		{"*", "mojo::examples::RemoteEcho", true},
+	}
+	for _, test := range tests {
+		if got := util.MatchInterface(test.list, test.iface); got != test.want {
+			t.Errorf("MatchInterface(%q, %q) = %v, want %v", test.list, test.iface, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"v.io/v23/naming"
+)
+
+// Address identifies a mojo interface served by a mojo app.
+type Address struct {
+	URL       string // e.g., https://mojo.v.io/echo_server.mojo
+	Interface string // e.g., mojo::examples::RemoteEcho
+}
+
+// EncodeSuffix returns the suffix at which a server proxy serves addr, i.e.,
+// the escaped URL and the escaped interface name as two name elements:
+//
+//	https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho
+//
+// Appending the suffix to the server proxy's endpoint yields the v23 name to
+// give to a client proxy.
+func EncodeSuffix(addr Address) string {
+	return naming.EncodeAsNameElement(addr.URL) + "/" + naming.EncodeAsNameElement(addr.Interface)
+}
+
+// DecodeSuffix is the inverse of EncodeSuffix. A suffix of a single element
+// is looked up in aliases, which may be nil.
+func DecodeSuffix(suffix string, aliases map[string]Address) (Address, error) {
+	elems := strings.Split(strings.Trim(suffix, "/"), "/")
+	switch len(elems) {
+	case 1:
+		if addr, ok := aliases[elems[0]]; ok && elems[0] != "" {
+			return addr, nil
+		}
+		return Address{}, fmt.Errorf("%q is not a known alias", suffix)
+	case 2:
+		url, ok := naming.DecodeFromNameElement(elems[0])
+		if !ok || url == "" {
+			return Address{}, fmt.Errorf("%q does not start with an escaped mojo url", suffix)
+		}
+		iface, ok := naming.DecodeFromNameElement(elems[1])
+		if !ok || iface == "" {
+			return Address{}, fmt.Errorf("%q does not end with an escaped interface name", suffix)
+		}
+		return Address{URL: url, Interface: iface}, nil
+	}
+	return Address{}, fmt.Errorf("%q has %d name elements, want <escaped url>/<escaped interface>", suffix, len(elems))
+}
+
+// ParseAliases parses a comma-separated list of <alias>=<suffix> pairs, where
+// each suffix is in the form returned by EncodeSuffix, e.g.,
+//
+//	echo=https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho
+func ParseAliases(spec string) (map[string]Address, error) {
+	aliases := map[string]Address{}
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "/") {
+			return nil, fmt.Errorf("invalid alias %q, want <alias>=<escaped url>/<escaped interface>", pair)
+		}
+		addr, err := DecodeSuffix(parts[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias %q: %v", pair, err)
+		}
+		aliases[parts[0]] = addr
+	}
+	return aliases, nil
+}
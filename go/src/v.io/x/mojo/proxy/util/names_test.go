@@ -0,0 +1,82 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util_test
+
+import (
+	"testing"
+
+	"v.io/x/mojo/proxy/util"
+)
+
+func TestSuffixRoundTrip(t *testing.T) {
+	tests := []util.Address{
+		{"https://mojo.v.io/echo_server.mojo", "mojo::examples::RemoteEcho"},
+		{"mojo:go_remote_echo_server", "mojo::examples::RemoteEcho"},
+		{"https://example.com/app.mojo?x=1&y=a%2Fb", "weird/interface%name"},
+	}
+	for _, addr := range tests {
+		suffix := util.EncodeSuffix(addr)
+		got, err := util.DecodeSuffix(suffix, nil)
+		if err != nil {
+			t.Errorf("DecodeSuffix(%q) failed: %v", suffix, err)
+			continue
+		}
+		if got != addr {
+			t.Errorf("DecodeSuffix(%q) = %v, want %v", suffix, got, addr)
+		}
+	}
+}
+
+func TestDecodeSuffix(t *testing.T) {
+	echo := util.Address{"https://mojo.v.io/echo_server.mojo", "mojo::examples::RemoteEcho"}
+	aliases := map[string]util.Address{"echo": echo}
+	tests := []struct {
+		suffix string
+		want   util.Address
+		ok     bool
+	}{
+		{"echo", echo, true},
+		{"https:%2F%2Fmojo.v.io%2Fecho_server.mojo/mojo::examples::RemoteEcho", echo, true},
+		{"", util.Address{}, false},
+		{"fortune", util.Address{}, false},
+		{"https://mojo.v.io/echo_server.mojo/mojo::examples::RemoteEcho", util.Address{}, false},
+		{"mojo:echo_server/", util.Address{}, false},
+		{"mojo%2/mojo::examples::RemoteEcho", util.Address{}, false},
+	}
+	for _, test := range tests {
+		got, err := util.DecodeSuffix(test.suffix, aliases)
+		if (err == nil) != test.ok {
+			t.Errorf("DecodeSuffix(%q) returned error %v, want ok %v", test.suffix, err, test.ok)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("DecodeSuffix(%q) = %v, want %v", test.suffix, got, test.want)
+		}
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	aliases, err := util.ParseAliases("echo=mojo:echo_server/mojo::examples::RemoteEcho, fortune=https:%2F%2Fmojo.v.io%2Ffortune_server.mojo/mojo::examples::Fortune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]util.Address{
+		"echo":    {"mojo:echo_server", "mojo::examples::RemoteEcho"},
+		"fortune": {"https://mojo.v.io/fortune_server.mojo", "mojo::examples::Fortune"},
+	}
+	if len(aliases) != len(want) {
+		t.Errorf("got %v, want %v", aliases, want)
+	}
+	for k, v := range want {
+		if aliases[k] != v {
+			t.Errorf("alias %q: got %v, want %v", k, aliases[k], v)
+		}
+	}
+	for _, bad := range []string{"echo", "=mojo:echo_server/iface", "a/b=mojo:echo_server/iface", "echo=mojo:echo_server"} {
+		if _, err := util.ParseAliases(bad); err == nil {
+			t.Errorf("ParseAliases(%q) should have failed", bad)
+		}
+	}
+}
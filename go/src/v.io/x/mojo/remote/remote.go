@@ -0,0 +1,149 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package remote calls mojo services served over Vanadium, e.g., by a
+// v23serverproxy, from Go programs that do not run in a mojo shell.
+//
+// Requests and responses are mojom-generated structs whose fields match the
+// parameters of the method called. For example, given
+//
+//	interface Fortune {
+//	  Get() => (string value);
+//	};
+//	struct FortuneGetRequest {};
+//	struct FortuneGetResponse {
+//	  string value;
+//	};
+//
+// a Go program can get a fortune with
+//
+//	c, err := remote.New("<endpoint>/fortune", (&fortune.Fortune_ServiceFactory{}).ServiceDescription())
+//	var resp fortune.FortuneGetResponse
+//	err = c.Call(ctx, "Get", &fortune.FortuneGetRequest{}, &resp)
+package remote
+
+import (
+	"fmt"
+
+	"mojo/public/go/bindings"
+	"mojo/public/interfaces/bindings/mojom_types"
+	"mojo/public/interfaces/bindings/service_describer"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vom"
+	"v.io/x/mojo/proxy/util"
+	"v.io/x/mojo/transcoder"
+)
+
+// Client calls the methods of the mojo service at a Vanadium name.
+type Client struct {
+	name     string
+	iface    mojom_types.MojomInterface
+	desc     map[string]mojom_types.UserDefinedType
+	defaults transcoder.Defaults
+	opts     []rpc.CallOpt
+}
+
+// New returns a client for the mojo service at name, whose interface is
+// described by sd. Like v23clientproxy, the client authorizes any server
+// unless opts include an options.ServerAuthorizer.
+func New(name string, sd service_describer.ServiceDescription, opts ...rpc.CallOpt) (*Client, error) {
+	iface, err := sd.GetTopLevelInterface()
+	if err != nil {
+		return nil, fmt.Errorf("service description has no interface: %v", err)
+	}
+	desc, err := sd.GetAllTypeDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("service description has no type definitions: %v", err)
+	}
+	defaults, err := transcoder.MojomDefaults(*desc)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) == 0 {
+		opts = []rpc.CallOpt{options.ServerAuthorizer{security.AllowEveryone()}}
+	}
+	return &Client{name: name, iface: iface, desc: *desc, defaults: defaults, opts: opts}, nil
+}
+
+// Call calls method with the parameters in request and decodes the results
+// into response. Both must be mojom structs whose fields have the types of
+// the method parameters, in order.
+func (c *Client) Call(ctx *context.T, method string, request, response bindings.Payload) error {
+	encoder := bindings.NewEncoder()
+	if err := request.Encode(encoder); err != nil {
+		return err
+	}
+	data, handles, err := encoder.Data()
+	if err != nil {
+		return err
+	}
+	if len(handles) > 0 {
+		return fmt.Errorf("%s.%s: handles cannot be sent over Vanadium", c.name, method)
+	}
+	out, err := c.CallMojom(ctx, method, data)
+	if err != nil {
+		return err
+	}
+	return response.Decode(bindings.NewDecoder(out, nil))
+}
+
+// CallMojom calls method with the mojom-encoded parameters in request and
+// returns the mojom-encoded results.
+func (c *Client) CallMojom(ctx *context.T, method string, request []byte) ([]byte, error) {
+	m, ok := c.method(method)
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %s", c.name, method)
+	}
+	if m.ResponseParams == nil {
+		// As in v23clientproxy, only methods with a response can be called.
+		return nil, fmt.Errorf("%s.%s has no response", c.name, method)
+	}
+
+	inType, err := transcoder.MojomStructToVDLType(m.Parameters, c.desc)
+	if err != nil {
+		return nil, err
+	}
+	target := util.StructSplitTarget()
+	if err := transcoder.FromMojo(target, request, inType, c.defaults); err != nil {
+		return nil, fmt.Errorf("transcoder.FromMojo failed: %v", err)
+	}
+	inargs := target.Fields()
+	inargsIfc := make([]interface{}, len(inargs))
+	for i := range inargs {
+		inargsIfc[i] = inargs[i]
+	}
+
+	outargs := make([]*vom.RawBytes, len(m.ResponseParams.Fields))
+	outptrs := make([]interface{}, len(outargs))
+	for i := range outargs {
+		outptrs[i] = &outargs[i]
+	}
+	if err := v23.GetClient(ctx).Call(ctx, c.name, method, inargsIfc, outptrs, c.opts...); err != nil {
+		return nil, err
+	}
+
+	outType, err := transcoder.MojomStructToVDLType(*m.ResponseParams, c.desc)
+	if err != nil {
+		return nil, err
+	}
+	toMojoTarget := transcoder.ToMojomTarget(c.defaults)
+	if err := util.JoinRawBytesAsStruct(toMojoTarget, outType, outargs); err != nil {
+		return nil, err
+	}
+	return toMojoTarget.Bytes(), nil
+}
+
+func (c *Client) method(name string) (mojom_types.MojomMethod, bool) {
+	for _, m := range c.iface.Methods {
+		if *m.DeclData.ShortName == name {
+			return m, true
+		}
+	}
+	return mojom_types.MojomMethod{}, false
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote_test
+
+import (
+	"reflect"
+	"testing"
+
+	"mojom/tests/end_to_end_test"
+
+	"v.io/v23/naming"
+	"v.io/v23/security"
+	"v.io/x/mojo/remote"
+	"v.io/x/mojo/server"
+	"v.io/x/mojo/tests/expected"
+	"v.io/x/mojo/tests/impl"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+func TestCall(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	factory := impl.ServiceFactory()
+	_, s, err := server.Serve(ctx, "", security.AllowEveryone(), server.Options{}, server.Service{Name: "test", Factory: factory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := remote.New(naming.Join(s.Status().Endpoints[0].Name(), "test"), factory.ServiceDescription())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var simple end_to_end_test.SimpleResponse
+	if err := c.Call(ctx, "Simple", &end_to_end_test.SimpleRequest{A: expected.SimpleRequestA}, &simple); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := simple.Value, expected.SimpleResponseValue; got != want {
+		t.Errorf("Simple: got %q, want %q", got, want)
+	}
+
+	var multi end_to_end_test.MultiArgsResponse
+	if err := c.Call(ctx, "MultiArgs", &end_to_end_test.MultiArgsRequest{
+		A: expected.MultiArgsRequestA,
+		B: expected.MultiArgsRequestB,
+		C: expected.MultiArgsRequestC,
+		D: expected.MultiArgsRequestD,
+	}, &multi); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(multi.X, expected.MultiArgsResponseX) || multi.Y != expected.MultiArgsResponseY {
+		t.Errorf("MultiArgs: got (%v, %q), want (%v, %q)", multi.X, multi.Y, expected.MultiArgsResponseX, expected.MultiArgsResponseY)
+	}
+
+	if err := c.Call(ctx, "Unknown", &end_to_end_test.SimpleRequest{}, &simple); err == nil {
+		t.Errorf("Call of an unknown method succeeded")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package server serves mojom-generated Go implementations over Vanadium,
+// without a mojo shell or a v23serverproxy. Calls are transcoded exactly as
+// v23serverproxy does, so mojo apps reach the services through a
+// v23clientproxy as usual, e.g., with client.Connect(ctx, r, "<name>/echo").
+//
+// For example, to serve the fortune example:
+//
+//	_, s, err := server.Serve(ctx, "", security.AllowEveryone(), server.Options{}, server.Service{
+//		Name:    "fortune",
+//		Factory: &fortune.Fortune_ServiceFactory{fortuneFactory},
+//	})
+package server
+
+import (
+	"fmt"
+
+	"mojo/public/go/application"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/x/mojo/internal/serverproxy"
+	"v.io/x/mojo/proxy/exports"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+	"v.io/x/mojo/proxy/util"
+)
+
+// urlPrefix is prepended to the name of a Service to make up the url under
+// which its calls are recorded, since the service has no mojo url.
+const urlPrefix = "go:"
+
+// defaultStatsPrefix names the stats of a dispatcher whose Options have no
+// StatsPrefix. It differs from the v23serverproxy's "v23proxy/server".
+const defaultStatsPrefix = "v23proxy/go-server"
+
+// Options configure a dispatcher.
+type Options struct {
+	// StatsPrefix names the dispatcher's per-method stats in Vanadium's stats
+	// registry, as in metrics.NewRecorder. Dispatchers in the same process
+	// need distinct prefixes. If empty, "v23proxy/go-server" is used.
+	StatsPrefix string
+}
+
+// Service is a mojo service to serve over Vanadium.
+type Service struct {
+	// Name is the suffix under which the service is served.
+	Name string
+	// Factory creates an implementation of the service for each call. The
+	// factories of mojom-generated code, e.g., echo.RemoteEcho_ServiceFactory,
+	// must be generated with type info.
+	Factory application.ServiceFactory
+	// Methods lists the methods that may be called. If empty, any method
+	// may be called.
+	Methods []string
+}
+
+// NewDispatcher returns a dispatcher that serves each of services under its
+// name, authorizing calls with auth, as configured by opts. The tags of the methods are their mojom
+// attributes, so auth may be, e.g., an access.PermissionsAuthorizer for
+// methods declared with [Access="Read"].
+func NewDispatcher(auth security.Authorizer, opts Options, services ...Service) (rpc.Dispatcher, error) {
+	factories := serverproxy.Factories{}
+	var list []exports.Export
+	for _, s := range services {
+		if s.Factory == nil {
+			return nil, fmt.Errorf("service %q has no factory", s.Name)
+		}
+		url := urlPrefix + s.Name
+		factories[url] = []application.ServiceFactory{s.Factory}
+		list = append(list, exports.Export{
+			Name:    s.Name,
+			Address: util.Address{URL: url, Interface: s.Factory.Name()},
+			Methods: s.Methods,
+		})
+	}
+	registry, err := exports.NewRegistry(list...)
+	if err != nil {
+		return nil, err
+	}
+	prefix := opts.StatsPrefix
+	if prefix == "" {
+		prefix = defaultStatsPrefix
+	}
+	return &serverproxy.Dispatcher{
+		Apps:     factories,
+		Sessions: session.NewRegistry(0),
+		Metrics:  metrics.NewRecorder(prefix, metrics.Transcode, metrics.App),
+		Gate:     &serverproxy.Gate{},
+		Auth:     auth,
+		Exports:  registry,
+	}, nil
+}
+
+// Serve serves services on a new Vanadium server that is published under
+// name, if name is not empty. The server stops when ctx is canceled.
+func Serve(ctx *context.T, name string, auth security.Authorizer, opts Options, services ...Service) (*context.T, rpc.Server, error) {
+	d, err := NewDispatcher(auth, opts, services...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v23.WithNewDispatchingServer(ctx, name, d)
+}
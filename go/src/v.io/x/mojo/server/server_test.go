@@ -0,0 +1,97 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"testing"
+
+	"mojo/public/go/bindings"
+
+	"mojom/tests/end_to_end_test"
+
+	"v.io/v23/naming"
+	"v.io/v23/security"
+	"v.io/x/mojo/internal/clientproxy"
+	"v.io/x/mojo/proxy/metrics"
+	"v.io/x/mojo/proxy/session"
+	"v.io/x/mojo/server"
+	"v.io/x/mojo/tests/expected"
+	"v.io/x/mojo/tests/impl"
+	_ "v.io/x/ref/runtime/factories/generic"
+	"v.io/x/ref/test"
+)
+
+func TestServe(t *testing.T) {
+	ctx, shutdown := test.V23Init()
+	defer shutdown()
+
+	_, s, err := server.Serve(ctx, "", security.AllowEveryone(), server.Options{StatsPrefix: "server-test/server"},
+		server.Service{
+			Name:    "test",
+			Factory: impl.ServiceFactory(),
+		},
+		server.Service{
+			Name:    "restricted",
+			Factory: impl.ServiceFactory(),
+			Methods: []string{"MultiArgs"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := s.Status().Endpoints[0].Name()
+
+	// Call the services through a client proxy, as a mojo app would.
+	proxy := &clientproxy.Proxy{
+		Sessions:           session.NewRegistry(0),
+		Metrics:            metrics.NewRecorder("server-test/client", metrics.Transcode, metrics.Network),
+		Traces:             clientproxy.NewTraceLog(),
+		MaxPipeConcurrency: 1,
+	}
+	connect := func(suffix string) *end_to_end_test.V23ProxyTest_Proxy {
+		r, p := end_to_end_test.CreateMessagePipeForV23ProxyTest()
+		sd := r.ServiceDescription()
+		iface, err := sd.GetTopLevelInterface()
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := sd.GetAllTypeDefinitions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proxy.Setup(ctx, naming.Join(ep, suffix), iface, *desc, r.Name(), r.PassMessagePipe(), clientproxy.Options{}); err != nil {
+			t.Fatal(err)
+		}
+		return end_to_end_test.NewV23ProxyTestProxy(p, bindings.GetAsyncWaiter())
+	}
+
+	client := connect("test")
+	defer client.Close_Proxy()
+	value, err := client.Simple(expected.SimpleRequestA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := value, expected.SimpleResponseValue; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	restricted := connect("restricted")
+	defer restricted.Close_Proxy()
+	if _, err := restricted.Simple(expected.SimpleRequestA); err == nil {
+		t.Errorf("Simple succeeded, but only MultiArgs is allowed")
+	}
+
+	unknown := connect("unknown")
+	defer unknown.Close_Proxy()
+	if _, err := unknown.Simple(expected.SimpleRequestA); err == nil {
+		t.Errorf("Simple succeeded on a service that is not served")
+	}
+}
+
+func TestNoFactory(t *testing.T) {
+	if _, err := server.NewDispatcher(security.AllowEveryone(), server.Options{}, server.Service{Name: "test"}); err == nil {
+		t.Errorf("NewDispatcher accepted a service without a factory")
+	}
+}
@@ -127,7 +127,9 @@ func createProxy(ctx application.Context) *end_to_end_test.V23ProxyTest_Proxy {
 	remoteName := *endpointFlag
 
 	r, p := end_to_end_test.CreateMessagePipeForV23ProxyTest()
-	v23.ConnectToRemoteService(ctx, &r, remoteName)
+	if err := v23.Connect(ctx, &r, remoteName); err != nil {
+		panic(err)
+	}
 	return end_to_end_test.NewV23ProxyTestProxy(p, bindings.GetAsyncWaiter())
 }
 
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	proxyutil "v.io/x/mojo/proxy/util"
 	"v.io/x/mojo/tests/expected"
 	"v.io/x/mojo/tests/util"
 )
@@ -44,7 +45,11 @@ func main() {
 		panic(err)
 	}
 	fmt.Println("DEBUG LOG: Starting server proxy...")
-	v23proxy, err := util.StartV23ServerProxy(wd)
+	suffix := proxyutil.EncodeSuffix(proxyutil.Address{
+		URL:       "https://mojo.v.io/" + serverMap[*serverType],
+		Interface: "mojo::v23proxy::tests::V23ProxyTest",
+	})
+	v23proxy, err := util.StartV23ServerProxy(wd, "--exports=test="+suffix)
 	if err != nil {
 		panic(err)
 	}
@@ -74,7 +79,7 @@ func main() {
 	}
 	fmt.Println("DEBUG LOG: Starting client proxy...")
 
-	endpointFlag := fmt.Sprintf("-endpoint=%s/https://mojo.v.io/%s/mojo::v23proxy::tests::V23ProxyTest", endpoint, serverMap[*serverType])
+	endpointFlag := fmt.Sprintf("-endpoint=%s/test", endpoint)
 	args := []string{endpointFlag, "--v23.tcp.address=127.0.0.1:0"}
 	if *runBench {
 		args = append(args, "-test.run=XXXX", "-test.bench=.")
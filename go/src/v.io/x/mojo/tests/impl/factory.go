@@ -0,0 +1,33 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"mojo/public/go/bindings"
+
+	"mojom/tests/end_to_end_test"
+)
+
+// Factory serves a new V23ProxyTestImpl on every request, as the in-process
+// test apps of the Go tests do.
+type Factory struct{}
+
+func (Factory) Create(r end_to_end_test.V23ProxyTest_Request) {
+	Serve(r, NewV23ProxyTestImpl())
+}
+
+// ServiceFactory returns the service factory for Factory.
+func ServiceFactory() *end_to_end_test.V23ProxyTest_ServiceFactory {
+	return &end_to_end_test.V23ProxyTest_ServiceFactory{Factory{}}
+}
+
+// Serve serves impl on the pipe of r until the pipe is closed.
+func Serve(r end_to_end_test.V23ProxyTest_Request, impl end_to_end_test.V23ProxyTest) {
+	stub := end_to_end_test.NewV23ProxyTestStub(r, impl, bindings.GetAsyncWaiter())
+	go func() {
+		for stub.ServeRequest() == nil {
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package impl implements the V23ProxyTest interface of the end-to-end tests.
+package impl
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"mojom/tests/end_to_end_test"
+
+	"v.io/x/mojo/tests/expected"
+)
+
+const noReturnReceiveTimeout = 1 * time.Second
+
+// V23ProxyTestImpl checks that it receives the expected arguments and
+// returns the expected results.
+type V23ProxyTestImpl struct {
+	noReturnMsgChan chan string
+}
+
+func NewV23ProxyTestImpl() *V23ProxyTestImpl {
+	return &V23ProxyTestImpl{noReturnMsgChan: make(chan string, 1)}
+}
+
+func (i *V23ProxyTestImpl) Simple(a int32) (value string, err error) {
+	if a != expected.SimpleRequestA {
+		return "", fmt.Errorf("expected %v, but got %v", expected.SimpleRequestA, a)
+	}
+	return expected.SimpleResponseValue, nil
+}
+
+func (i *V23ProxyTestImpl) MultiArgs(a bool, b []float32, c map[string]uint8, d end_to_end_test.AStruct) (x end_to_end_test.AUnion, y string, err error) {
+	if a != expected.MultiArgsRequestA {
+		return nil, "", fmt.Errorf("expected %v, but got %v", expected.MultiArgsRequestA, a)
+	}
+	if !reflect.DeepEqual(b, expected.MultiArgsRequestB) {
+		return nil, "", fmt.Errorf("expected %v, but got %v", expected.MultiArgsRequestB, b)
+	}
+	if !reflect.DeepEqual(c, expected.MultiArgsRequestC) {
+		return nil, "", fmt.Errorf("expected %v, but got %v", expected.MultiArgsRequestC, c)
+	}
+	if !reflect.DeepEqual(d, expected.MultiArgsRequestD) {
+		return nil, "", fmt.Errorf("expected %v, but got %v", expected.MultiArgsRequestD, d)
+	}
+	return expected.MultiArgsResponseX, expected.MultiArgsResponseY, nil
+}
+
+func (i *V23ProxyTestImpl) NoOutArgsPut(storedMsg string) error {
+	i.noReturnMsgChan <- storedMsg
+	return nil
+}
+
+func (i *V23ProxyTestImpl) FetchMsgFromNoOutArgsPut() (string, error) {
+	select {
+	case msg := <-i.noReturnMsgChan:
+		return msg, nil
+	case <-time.After(noReturnReceiveTimeout):
+		return "", fmt.Errorf("timed out waiting for no return message")
+	}
+}
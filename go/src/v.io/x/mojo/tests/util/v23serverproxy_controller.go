@@ -17,8 +17,10 @@ import (
 	"syscall"
 )
 
-func StartV23ServerProxy(v23ProxyRoot string) (*V23ProxyController, error) {
-	cmd := RunMojoShellForV23ProxyTests("v23serverproxy.mojo", v23ProxyRoot, []string{"--v23.tcp.address=127.0.0.1:0"})
+// StartV23ServerProxy starts a mojo shell running the v23serverproxy. args are
+// passed to the proxy in addition to the default ones.
+func StartV23ServerProxy(v23ProxyRoot string, args ...string) (*V23ProxyController, error) {
+	cmd := RunMojoShellForV23ProxyTests("v23serverproxy.mojo", v23ProxyRoot, append([]string{"--v23.tcp.address=127.0.0.1:0"}, args...))
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
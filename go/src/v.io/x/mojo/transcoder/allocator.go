@@ -15,6 +15,10 @@ type allocator struct {
 
 	// Index of the first unclaimed byte in buf.
 	end uint32
+
+	// The defaults of the fields of the structs being encoded, which every
+	// target of the encoding reaches through its bytesRef.
+	defaults Defaults
 }
 
 func (a *allocator) makeRoom(size uint32) {
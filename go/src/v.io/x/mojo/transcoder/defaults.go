@@ -0,0 +1,157 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"fmt"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+)
+
+// Mojom struct fields may have default values, e.g.,
+//
+//	struct Config {
+//	  int32 retries = 3;
+//	};
+//
+// VDL types cannot hold them, so MojomDefaults returns the defaults of the
+// structs of a mojom description, by VDL type, and the functions of the
+// transcoder take them along with the types of the values:
+//
+//   - FromMojo sets the fields that the encoded struct is too short to hold,
+//     e.g., when it was encoded by a peer with an older version of the struct.
+//   - ToMojom and the targets of ToMojomTarget set the fields that the source
+//     of the struct neither started nor zeroed.
+//   - FromJSON sets the fields that the JSON object does not have.
+//
+// Fields without a default get their zero value, as do all the fields of the
+// structs that are not in the Defaults, e.g., for types that were not
+// converted from mojom. Only literal defaults are supported; the fields whose
+// default refers to a constant or an enum value get their zero value.
+
+// Defaults maps VDL struct types to the mojom default values of their fields,
+// indexed like the fields, with nil for the fields that have none. A nil
+// Defaults gives every field its zero value.
+type Defaults map[*vdl.Type][]*vdl.Value
+
+// MojomDefaults returns the defaults of the named structs in mp that have
+// any. The structs that have no VDL type, e.g., because they hold handles, are
+// skipped.
+func MojomDefaults(mp map[string]mojom_types.UserDefinedType) (Defaults, error) {
+	defaults := Defaults{}
+	for typeKey, udt := range mp {
+		st, ok := udt.(*mojom_types.UserDefinedTypeStructType)
+		if !ok {
+			continue
+		}
+		typeKey := typeKey
+		t, err := MojomToVDLType(&mojom_types.TypeTypeReference{
+			mojom_types.TypeReference{TypeKey: &typeKey},
+		}, mp)
+		if err != nil {
+			continue
+		}
+		values, err := structDefaults(t, st.Value)
+		if err != nil {
+			return nil, err
+		}
+		if values != nil {
+			defaults[t] = values
+		}
+	}
+	return defaults, nil
+}
+
+// Fields returns the defaults of the fields of the struct type t, indexed like
+// its fields, or nil if none of them has one.
+func (d Defaults) Fields(t *vdl.Type) []*vdl.Value {
+	return d[t]
+}
+
+// field returns the value that an absent field of the struct type t takes.
+func (d Defaults) field(t *vdl.Type, index int) *vdl.Value {
+	if values := d[t]; values != nil && values[index] != nil {
+		return values[index]
+	}
+	return vdl.ZeroValue(t.Field(index).Type)
+}
+
+// structDefaults returns the defaults of the fields of ms, which was converted
+// to t, or nil if none of them has one.
+func structDefaults(t *vdl.Type, ms mojom_types.MojomStruct) ([]*vdl.Value, error) {
+	var values []*vdl.Value
+	for i, field := range ms.Fields {
+		value, err := defaultValue(field.DefaultValue, t.Field(i).Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t.Name(), t.Field(i).Name, err)
+		}
+		if value == nil {
+			continue
+		}
+		if values == nil {
+			values = make([]*vdl.Value, t.NumField())
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// defaultValue returns the value of type t for the mojom default dv, or nil if
+// the field has no default that the transcoder supports.
+func defaultValue(dv mojom_types.DefaultFieldValue, t *vdl.Type) (*vdl.Value, error) {
+	v, ok := dv.(*mojom_types.DefaultFieldValueValue)
+	if !ok {
+		// The default keyword gives the field its zero value.
+		return nil, nil
+	}
+	literal, ok := v.Value.(*mojom_types.ValueLiteralValue)
+	if !ok {
+		return nil, nil
+	}
+	x, err := literalToGo(literal.Value)
+	if err != nil {
+		return nil, err
+	}
+	// The literal may have another type than the field, e.g., 1 for a double,
+	// so it is converted by the VDL rules, which reject out of range values.
+	value := vdl.ZeroValue(t)
+	if err := vdl.Convert(value, x); err != nil {
+		return nil, fmt.Errorf("invalid default value %v: %v", x, err)
+	}
+	return value, nil
+}
+
+// literalToGo returns the Go value of a mojom literal.
+func literalToGo(literal mojom_types.LiteralValue) (interface{}, error) {
+	switch l := literal.(type) {
+	case *mojom_types.LiteralValueBoolValue:
+		return l.Value, nil
+	case *mojom_types.LiteralValueDoubleValue:
+		return l.Value, nil
+	case *mojom_types.LiteralValueFloatValue:
+		return l.Value, nil
+	case *mojom_types.LiteralValueInt8Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueInt16Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueInt32Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueInt64Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueStringValue:
+		return l.Value, nil
+	case *mojom_types.LiteralValueUint8Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueUint16Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueUint32Value:
+		return l.Value, nil
+	case *mojom_types.LiteralValueUint64Value:
+		return l.Value, nil
+	}
+	return nil, fmt.Errorf("unknown literal %#v", literal)
+}
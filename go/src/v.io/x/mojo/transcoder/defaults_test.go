@@ -0,0 +1,136 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+)
+
+// configType returns the VDL type and the defaults of the mojom struct
+//
+//	struct Config {
+//	  string name;
+//	  int32 retries = <retries>;
+//	};
+func configType(t *testing.T, retries int8) (*vdl.Type, transcoder.Defaults) {
+	ident, key, nameField, retriesField := "test.defaults.Config", "TYPE_KEY:test.defaults.Config", "name", "retries"
+	desc := map[string]mojom_types.UserDefinedType{
+		key: &mojom_types.UserDefinedTypeStructType{mojom_types.MojomStruct{
+			DeclData: &mojom_types.DeclarationData{FullIdentifier: &ident},
+			Fields: []mojom_types.StructField{
+				{
+					DeclData: &mojom_types.DeclarationData{ShortName: &nameField},
+					Type:     &mojom_types.TypeStringType{mojom_types.StringType{}},
+				},
+				{
+					DeclData: &mojom_types.DeclarationData{ShortName: &retriesField},
+					Type:     &mojom_types.TypeSimpleType{mojom_types.SimpleType_Int32},
+					// The parser gives small literals the smallest type.
+					DefaultValue: &mojom_types.DefaultFieldValueValue{&mojom_types.ValueLiteralValue{&mojom_types.LiteralValueInt8Value{retries}}},
+				},
+			},
+		}},
+	}
+	vt, err := transcoder.MojomToVDLType(&mojom_types.TypeTypeReference{mojom_types.TypeReference{TypeKey: &key}}, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := transcoder.MojomDefaults(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vt, defaults
+}
+
+func TestMojomDefaults(t *testing.T) {
+	vt, defaults := configType(t, 3)
+	values := defaults.Fields(vt)
+	if got, want := len(values), 2; got != want {
+		t.Fatalf("got %d defaults, want %d", got, want)
+	}
+	if values[0] != nil {
+		t.Errorf("got default %v for Name, want none", values[0])
+	}
+	if want := vdl.Int32Value(3); !vdl.EqualValue(values[1], want) {
+		t.Errorf("got default %v for Retries, want %v", values[1], want)
+	}
+	if got := defaults.Fields(vdl.StructType(vdl.Field{"Retries", vdl.Int32Type})); got != nil {
+		t.Errorf("got defaults %v for a type without any", got)
+	}
+
+	// Versions of the struct that differ only in their defaults have the same
+	// VDL type, but keep their own defaults.
+	vt5, defaults5 := configType(t, 5)
+	if vt5 != vt {
+		t.Fatalf("got types %v and %v, want the same", vt, vt5)
+	}
+	if got, want := defaults5.Fields(vt)[1], vdl.Int32Value(5); !vdl.EqualValue(got, want) {
+		t.Errorf("got default %v, want %v", got, want)
+	}
+	if got, want := defaults.Fields(vt)[1], vdl.Int32Value(3); !vdl.EqualValue(got, want) {
+		t.Errorf("got default %v after converting another version, want %v", got, want)
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	vt, defaults := configType(t, 3)
+
+	// An older version of the struct, without the retries field.
+	old := vdl.ZeroValue(vdl.NamedType("test.defaults.Config", vdl.StructType(vdl.Field{"Name", vdl.StringType})))
+	old.StructField(0).AssignString("a")
+	data, err := transcoder.ToMojom(old, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *vdl.Value
+	if err := transcoder.ValueFromMojo(&got, data, vt, defaults); err != nil {
+		t.Fatal(err)
+	}
+	want := vdl.ZeroValue(vt)
+	want.StructField(0).AssignString("a")
+	want.StructField(1).AssignInt(3)
+	if !vdl.EqualValue(got, want) {
+		t.Errorf("decoding an older version: got %v, want %v", got, want)
+	}
+
+	// A value that is set to zero keeps it.
+	zero := vdl.ZeroValue(vt)
+	if data, err = transcoder.ToMojom(zero, defaults); err != nil {
+		t.Fatal(err)
+	}
+	if err := transcoder.ValueFromMojo(&got, data, vt, defaults); err != nil {
+		t.Fatal(err)
+	}
+	if !vdl.EqualValue(got, zero) {
+		t.Errorf("got %v, want %v", got, zero)
+	}
+}
+
+func TestJSONDefaults(t *testing.T) {
+	vt, defaults := configType(t, 3)
+	tests := []struct {
+		json    string
+		retries int64
+	}{
+		{`{"Name": "a"}`, 3},
+		{`{"Name": "a", "Retries": 0}`, 0},
+		{`{"Retries": 5}`, 5},
+	}
+	for _, test := range tests {
+		v, err := transcoder.JSONToValue([]byte(test.json), vt, defaults)
+		if err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		if got := v.StructField(1).Int(); got != test.retries {
+			t.Errorf("%s: got retries %d, want %d", test.json, got, test.retries)
+		}
+	}
+}
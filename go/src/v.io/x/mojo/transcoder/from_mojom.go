@@ -14,27 +14,29 @@ import (
 )
 
 // FromMojo decodes the mojom-encoded data into valptr, which must be a pointer to
-// the desired value.  The datatype describes the type of the encoded data.
+// the desired value.  The datatype describes the type of the encoded data, and
+// defaults the values of the struct fields that the data is too short to hold.
 // Returns an error if the data cannot be decoded into valptr, based on the VDL
 // value conversion rules.
 // TODO(bprosnitz) Consider reimplementing this using mojom_type instead of vdl type
 // so that we can take advantage of the struct offset in the mojom type.
-func ValueFromMojo(valptr interface{}, data []byte, datatype *vdl.Type) error {
+func ValueFromMojo(valptr interface{}, data []byte, datatype *vdl.Type, defaults Defaults) error {
 	target, err := vdl.ReflectTarget(reflect.ValueOf(valptr))
 	if err != nil {
 		return err
 	}
-	return FromMojo(target, data, datatype)
+	return FromMojo(target, data, datatype, defaults)
 }
 
-func FromMojo(target vdl.Target, data []byte, datatype *vdl.Type) error {
-	mtv := &mojomToTargetTranscoder{modec: bindings.NewDecoder(data, nil)}
+func FromMojo(target vdl.Target, data []byte, datatype *vdl.Type, defaults Defaults) error {
+	mtv := &mojomToTargetTranscoder{modec: bindings.NewDecoder(data, nil), defaults: defaults}
 	return mtv.transcodeValue(datatype, target, true, false)
 }
 
 type mojomToTargetTranscoder struct {
 	modec     *bindings.Decoder
 	typeStack []*vdl.Type
+	defaults  Defaults
 }
 
 func (mtv *mojomToTargetTranscoder) transcodeValue(vt *vdl.Type, target vdl.Target, isTopType, isNullable bool) error {
@@ -283,7 +285,7 @@ func (mtv *mojomToTargetTranscoder) transcodeValue(vt *vdl.Type, target vdl.Targ
 				return fmt.Errorf("invalid null struct pointer")
 			}
 		}
-		_, err := mtv.modec.StartStruct()
+		header, err := mtv.modec.StartStruct()
 		if err != nil {
 			return err
 		}
@@ -293,20 +295,25 @@ func (mtv *mojomToTargetTranscoder) transcodeValue(vt *vdl.Type, target vdl.Targ
 		}
 		for _, alloc := range computeStructLayout(vt) {
 			mfield := vt.Field(alloc.vdlStructIndex)
-			switch vkey, vfield, err := targetFields.StartField(mfield.Name); {
+			vkey, vfield, err := targetFields.StartField(mfield.Name)
 			// TODO(toddw): Handle err == vdl.ErrFieldNoExist case?
-			case err != nil:
+			if err != nil {
 				return err
-			default:
-				if err := mtv.transcodeValue(mfield.Type, vfield, false, false); err != nil {
-					return err
-				}
-				if err := targetFields.FinishField(vkey, vfield); err != nil {
+			}
+			// The struct may have been encoded by a peer with an older
+			// version of it, without the fields that were appended since.
+			// The fields that are past its end take their default value.
+			if HEADER_SIZE+alloc.byteOffset+(baseTypeSizeBits(mfield.Type)+7)/8 > header.Size {
+				if err := vdl.FromValue(vfield, mtv.defaults.field(vt, alloc.vdlStructIndex)); err != nil {
 					return err
 				}
+			} else if err := mtv.transcodeValue(mfield.Type, vfield, false, false); err != nil {
+				return err
+			}
+			if err := targetFields.FinishField(vkey, vfield); err != nil {
+				return err
 			}
 		}
-		// TODO(toddw): Fill in fields that weren't decoded with their zero value.
 		if err := target.FinishFields(targetFields); err != nil {
 			return err
 		}
@@ -0,0 +1,284 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"v.io/v23/vdl"
+)
+
+// Inspect returns an annotated dump of the mojom encoding of a value of type
+// t in data: the offset and contents of every struct header, field, pointer,
+// array header and union tag, followed by the value that FromMojo decodes.
+// If data cannot be decoded, the dump marks the place where decoding stops,
+// and err describes the problem. The fields of structs have the given
+// defaults.
+func Inspect(data []byte, t *vdl.Type, defaults Defaults) (dump string, err error) {
+	in := &inspector{data: data, defaults: defaults}
+	switch t.Kind() {
+	case vdl.Struct:
+		err = in.object(t, 0, 0)
+	case vdl.Union:
+		err = in.union(t, 0, 0, "", false)
+	default:
+		err = fmt.Errorf("top-level type %v is not a struct or union", t)
+	}
+	if err != nil {
+		in.line(in.last, 0, "!! decoding stops here: %v", err)
+		return in.buf.String(), err
+	}
+	var value *vdl.Value
+	if err := ValueFromMojo(&value, data, t, defaults); err != nil {
+		fmt.Fprintf(&in.buf, "!! FromMojo failed: %v\n", err)
+		return in.buf.String(), err
+	}
+	fmt.Fprintf(&in.buf, "value: %v\n", value)
+	return in.buf.String(), nil
+}
+
+type inspector struct {
+	data     []byte
+	defaults Defaults
+	buf      bytes.Buffer
+	last     int // offset of the last item that was read
+}
+
+func (in *inspector) line(offset, depth int, format string, args ...interface{}) {
+	fmt.Fprintf(&in.buf, "%06x  %s%s\n", offset, strings.Repeat("  ", depth), fmt.Sprintf(format, args...))
+}
+
+// read returns the n bytes at offset.
+func (in *inspector) read(offset, n int) ([]byte, error) {
+	if offset < 0 || offset+n > len(in.data) {
+		return nil, fmt.Errorf("%d bytes at offset %#x are out of bounds (data has %d bytes)", n, offset, len(in.data))
+	}
+	in.last = offset
+	return in.data[offset : offset+n], nil
+}
+
+func (in *inspector) uint32(offset int) (uint32, error) {
+	b, err := in.read(offset, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// header reads the struct or array header at offset.
+func (in *inspector) header(offset int) (size, count uint32, err error) {
+	if size, err = in.uint32(offset); err != nil {
+		return
+	}
+	if count, err = in.uint32(offset + 4); err != nil {
+		return
+	}
+	if size < 8 {
+		err = fmt.Errorf("header size %d is smaller than the header", size)
+	} else if offset+int(size) > len(in.data) {
+		err = fmt.Errorf("header size %d exceeds the data", size)
+	}
+	return
+}
+
+// object dumps the struct, array, string or map of type t at offset.
+func (in *inspector) object(t *vdl.Type, offset, depth int) error {
+	switch t.Kind() {
+	case vdl.Struct:
+		size, version, err := in.header(offset)
+		if err != nil {
+			return err
+		}
+		in.line(offset, depth, "struct %s header: size=%d version=%d", typeName(t), size, version)
+		for _, alloc := range computeStructLayout(t) {
+			field := t.Field(alloc.vdlStructIndex)
+			fieldOffset := offset + 8 + int(alloc.byteOffset)
+			if n := (baseTypeSizeBits(field.Type) + 7) / 8; fieldOffset+int(n) > offset+int(size) {
+				// An older version of the struct, which FromMojo decodes
+				// with the field's default.
+				in.line(fieldOffset, depth+1, "field %s (%s): absent, default %v", field.Name, typeName(field.Type), in.defaults.field(t, alloc.vdlStructIndex))
+				continue
+			}
+			if err := in.inline(field.Type, fieldOffset, alloc.bitOffset, depth+1, "field "+field.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case vdl.String:
+		size, count, err := in.header(offset)
+		if err != nil {
+			return err
+		}
+		b, err := in.read(offset+8, int(count))
+		if err != nil {
+			return err
+		}
+		in.line(offset, depth, "string header: size=%d length=%d %q", size, count, b)
+		return nil
+	case vdl.Array, vdl.List:
+		size, count, err := in.header(offset)
+		if err != nil {
+			return err
+		}
+		in.line(offset, depth, "array %s header: size=%d length=%d", typeName(t), size, count)
+		if t.Kind() == vdl.Array && int(count) != t.Len() {
+			return fmt.Errorf("array has %d elements, want %d", count, t.Len())
+		}
+		if t.IsBytes() {
+			b, err := in.read(offset+8, int(count))
+			if err != nil {
+				return err
+			}
+			in.line(offset+8, depth+1, "bytes %x", b)
+			return nil
+		}
+		bits := int(baseTypeSizeBits(t.Elem()))
+		for i := 0; i < int(count); i++ {
+			bit := i * bits
+			if err := in.inline(t.Elem(), offset+8+bit/8, uint8(bit%8), depth+1, fmt.Sprintf("[%d]", i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case vdl.Map:
+		size, version, err := in.header(offset)
+		if err != nil {
+			return err
+		}
+		in.line(offset, depth, "map %s header: size=%d version=%d", typeName(t), size, version)
+		if err := in.inline(vdl.ListType(t.Key()), offset+8, 0, depth+1, "keys"); err != nil {
+			return err
+		}
+		return in.inline(vdl.ListType(t.Elem()), offset+16, 0, depth+1, "values")
+	default:
+		return fmt.Errorf("%v is not encoded behind a pointer", t)
+	}
+}
+
+// inline dumps the value of type t that is stored in a struct field or array
+// element at offset.
+func (in *inspector) inline(t *vdl.Type, offset int, bit uint8, depth int, label string) error {
+	nullable := false
+	if t.Kind() == vdl.Optional {
+		t, nullable = t.Elem(), true
+	}
+	switch t.Kind() {
+	case vdl.Union:
+		return in.union(t, offset, depth, label, nullable)
+	case vdl.String, vdl.Array, vdl.List, vdl.Map, vdl.Struct:
+		b, err := in.read(offset, 8)
+		if err != nil {
+			return err
+		}
+		ptr := binary.LittleEndian.Uint64(b)
+		if ptr == 0 {
+			in.line(offset, depth, "%s: null pointer", label)
+			if !nullable {
+				return fmt.Errorf("%s: invalid null pointer", label)
+			}
+			return nil
+		}
+		target := offset + int(ptr)
+		in.line(offset, depth, "%s: pointer +%d -> %06x", label, ptr, target)
+		return in.object(t, target, depth+1)
+	}
+	value, err := in.scalar(t, offset, bit)
+	if err != nil {
+		return err
+	}
+	in.line(offset, depth, "%s (%s): %s", label, typeName(t), value)
+	return nil
+}
+
+// union dumps the union of type t at offset.
+func (in *inspector) union(t *vdl.Type, offset, depth int, label string, nullable bool) error {
+	if label != "" {
+		label += ": "
+	}
+	size, err := in.uint32(offset)
+	if err != nil {
+		return err
+	}
+	tag, err := in.uint32(offset + 4)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		in.line(offset, depth, "%snull union %s", label, typeName(t))
+		if !nullable {
+			return fmt.Errorf("invalid null union")
+		}
+		return nil
+	}
+	in.line(offset, depth, "%sunion %s header: size=%d tag=%d", label, typeName(t), size, tag)
+	if int(tag) >= t.NumField() {
+		return fmt.Errorf("union tag %d out of bounds", tag)
+	}
+	field := t.Field(int(tag))
+	if field.Type.Kind() == vdl.Union {
+		// Nested unions are stored behind a pointer.
+		b, err := in.read(offset+8, 8)
+		if err != nil {
+			return err
+		}
+		ptr := binary.LittleEndian.Uint64(b)
+		in.line(offset+8, depth+1, "field %s: pointer +%d -> %06x", field.Name, ptr, offset+8+int(ptr))
+		return in.union(field.Type, offset+8+int(ptr), depth+2, "", false)
+	}
+	return in.inline(field.Type, offset+8, 0, depth+1, "field "+field.Name)
+}
+
+// scalar formats the value of type t, which is neither stored behind a
+// pointer nor a union, at offset.
+func (in *inspector) scalar(t *vdl.Type, offset int, bit uint8) (string, error) {
+	b, err := in.read(offset, int((baseTypeSizeBits(t)+7)/8))
+	if err != nil {
+		return "", err
+	}
+	switch t.Kind() {
+	case vdl.Bool:
+		return fmt.Sprint(b[0]&(1<<bit) != 0), nil
+	case vdl.Int8:
+		return fmt.Sprint(int8(b[0])), nil
+	case vdl.Byte:
+		return fmt.Sprint(b[0]), nil
+	case vdl.Int16:
+		return fmt.Sprint(int16(binary.LittleEndian.Uint16(b))), nil
+	case vdl.Uint16:
+		return fmt.Sprint(binary.LittleEndian.Uint16(b)), nil
+	case vdl.Int32:
+		return fmt.Sprint(int32(binary.LittleEndian.Uint32(b))), nil
+	case vdl.Uint32:
+		return fmt.Sprint(binary.LittleEndian.Uint32(b)), nil
+	case vdl.Int64:
+		return fmt.Sprint(int64(binary.LittleEndian.Uint64(b))), nil
+	case vdl.Uint64:
+		return fmt.Sprint(binary.LittleEndian.Uint64(b)), nil
+	case vdl.Float32:
+		return fmt.Sprint(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case vdl.Float64:
+		return fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(b))), nil
+	case vdl.Enum:
+		index := int32(binary.LittleEndian.Uint32(b))
+		if index < 0 || int(index) >= t.NumEnumLabel() {
+			return "", fmt.Errorf("enum label %d out of range", index)
+		}
+		return fmt.Sprintf("%s (%d)", t.EnumLabel(int(index)), index), nil
+	default:
+		return "", fmt.Errorf("cannot inspect values of type %v", t)
+	}
+}
+
+// typeName returns the name of t, or its kind if it is unnamed.
+func typeName(t *vdl.Type) string {
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.Kind().String()
+}
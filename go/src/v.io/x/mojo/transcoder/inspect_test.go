@@ -0,0 +1,81 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"strings"
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+)
+
+type inspectPoint struct {
+	X, Y int32
+	Name string
+	Tags []string
+}
+
+func TestInspect(t *testing.T) {
+	value := inspectPoint{X: 1, Y: -2, Name: "origin", Tags: []string{"a", "b"}}
+	data, err := transcoder.ToMojom(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := transcoder.Inspect(data, vdl.TypeOf(value), nil)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, dump)
+	}
+	for _, want := range []string{
+		"000000  struct ",
+		"field X (int32): 1",
+		"field Y (int32): -2",
+		`length=6 "origin"`,
+		`length=1 "b"`,
+		"value: ",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump does not contain %q:\n%s", want, dump)
+		}
+	}
+
+	// Truncated data must be reported where the dump stops.
+	dump, err = transcoder.Inspect(data[:len(data)-12], vdl.TypeOf(value), nil)
+	if err == nil {
+		t.Fatalf("inspected truncated data without error:\n%s", dump)
+	}
+	if !strings.Contains(dump, "!! decoding stops here") {
+		t.Errorf("dump does not mark where decoding stops:\n%s", dump)
+	}
+	if !strings.Contains(dump, "field X (int32): 1") {
+		t.Errorf("dump does not include the fields before the error:\n%s", dump)
+	}
+}
+
+type inspectPointV0 struct {
+	X, Y int32
+}
+
+func TestInspectAbsentFields(t *testing.T) {
+	data, err := transcoder.ToMojom(inspectPointV0{X: 1, Y: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := vdl.TypeOf(inspectPoint{})
+	defaults := transcoder.Defaults{tt: {nil, nil, vdl.StringValue("none"), nil}}
+	dump, err := transcoder.Inspect(data, tt, defaults)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, dump)
+	}
+	for _, want := range []string{
+		"field Y (int32): 2",
+		`field Name (string): absent, default "none"`,
+		"field Tags (list): absent, default",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump does not contain %q:\n%s", want, dump)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"v.io/v23/vdl"
+)
+
+// The JSON form of values, used by the functions below, is:
+//
+//   bool, integers, floats  JSON booleans and numbers
+//   string                  JSON string
+//   bytes ([]byte, [N]byte) base64-encoded JSON string
+//   list, array             JSON array
+//   enum                    JSON string holding the label
+//   struct                  JSON object keyed by field name
+//   union                   JSON object with a single key, the field name
+//   optional                null, or the JSON form of the value
+//   map with string or      JSON object
+//     enum keys
+//   other maps              JSON array of [key, value] arrays
+//
+// Field names and enum labels are those of the VDL type, i.e. the mojom names
+// converted by MojomToVDLFieldName and MojomToVDLEnumLabel. FromJSON sets the
+// struct fields that are missing from the JSON to their mojom default value,
+// or to their zero value. The type of mojom descriptors is obtained with
+// MojomStructToVDLType, and their defaults with MojomDefaults.
+
+// ToJSON decodes the mojom-encoded data, a value of type t whose struct fields
+// have the given defaults, into JSON.
+func ToJSON(data []byte, t *vdl.Type, defaults Defaults) ([]byte, error) {
+	var value *vdl.Value
+	if err := ValueFromMojo(&value, data, t, defaults); err != nil {
+		return nil, err
+	}
+	return ValueToJSON(value)
+}
+
+// FromJSON encodes the JSON form of a value of type t, whose struct fields have
+// the given defaults, as mojom.
+func FromJSON(data []byte, t *vdl.Type, defaults Defaults) ([]byte, error) {
+	value, err := JSONToValue(data, t, defaults)
+	if err != nil {
+		return nil, err
+	}
+	return ToMojom(value, defaults)
+}
+
+// ValueToJSON returns the JSON form of v.
+func ValueToJSON(v *vdl.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONToValue converts the JSON form of a value of type t to a *vdl.Value,
+// giving the struct fields that the JSON lacks their value in defaults.
+func JSONToValue(data []byte, t *vdl.Type, defaults Defaults) (*vdl.Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, err
+	}
+	return jsonToValue(x, t, defaults)
+}
+
+func writeJSON(buf *bytes.Buffer, v *vdl.Value) error {
+	switch v.Kind() {
+	case vdl.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case vdl.Float32, vdl.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return fmt.Errorf("%v cannot be represented in JSON", f)
+		}
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize(v.Kind())))
+	case vdl.String:
+		writeJSONString(buf, v.RawString())
+	case vdl.Enum:
+		writeJSONString(buf, v.EnumLabel())
+	case vdl.Array, vdl.List:
+		if v.Type().IsBytes() {
+			writeJSONString(buf, base64.StdEncoding.EncodeToString(v.Bytes()))
+			return nil
+		}
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeJSON(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case vdl.Map:
+		return writeJSONMap(buf, v)
+	case vdl.Struct:
+		buf.WriteString("{")
+		for i := 0; i < v.Type().NumField(); i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			writeJSONString(buf, v.Type().Field(i).Name)
+			buf.WriteString(":")
+			if err := writeJSON(buf, v.StructField(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case vdl.Union:
+		index, field := v.UnionField()
+		buf.WriteString("{")
+		writeJSONString(buf, v.Type().Field(index).Name)
+		buf.WriteString(":")
+		if err := writeJSON(buf, field); err != nil {
+			return err
+		}
+		buf.WriteString("}")
+	case vdl.Optional:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, v.Elem())
+	default:
+		return fmt.Errorf("values of type %v cannot be represented in JSON", v.Type())
+	}
+	return nil
+}
+
+// writeJSONMap writes the entries of the map v, sorted so that the output is
+// stable.
+func writeJSONMap(buf *bytes.Buffer, v *vdl.Value) error {
+	objectKeys := hasStringKeys(v.Type())
+	entries := make([]string, 0, v.Len())
+	for _, key := range v.Keys() {
+		var entry bytes.Buffer
+		if !objectKeys {
+			entry.WriteString("[")
+		}
+		if err := writeJSON(&entry, key); err != nil {
+			return err
+		}
+		if objectKeys {
+			entry.WriteString(":")
+		} else {
+			entry.WriteString(",")
+		}
+		if err := writeJSON(&entry, v.MapIndex(key)); err != nil {
+			return err
+		}
+		if !objectKeys {
+			entry.WriteString("]")
+		}
+		entries = append(entries, entry.String())
+	}
+	sort.Strings(entries)
+	if objectKeys {
+		buf.WriteString("{")
+	} else {
+		buf.WriteString("[")
+	}
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(entry)
+	}
+	if objectKeys {
+		buf.WriteString("}")
+	} else {
+		buf.WriteString("]")
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s) // Marshaling a string cannot fail.
+	buf.Write(b)
+}
+
+// hasStringKeys returns true if the keys of the map type t are represented as
+// JSON strings.
+func hasStringKeys(t *vdl.Type) bool {
+	k := t.Key().Kind()
+	return k == vdl.String || k == vdl.Enum
+}
+
+// jsonToValue converts x, as decoded by encoding/json with UseNumber, to a
+// value of type t.
+func jsonToValue(x interface{}, t *vdl.Type, defaults Defaults) (*vdl.Value, error) {
+	if t.Kind() == vdl.Optional {
+		if x == nil {
+			return vdl.ZeroValue(t), nil
+		}
+		elem, err := jsonToValue(x, t.Elem(), defaults)
+		if err != nil {
+			return nil, err
+		}
+		return vdl.OptionalValue(elem), nil
+	}
+	if x == nil {
+		return nil, fmt.Errorf("null is not a valid %v", t)
+	}
+	v := vdl.ZeroValue(t)
+	switch t.Kind() {
+	case vdl.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		v.AssignBool(b)
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		u, err := strconv.ParseUint(string(n), 10, bitSize(t.Kind()))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid %v: %v", n, t, err)
+		}
+		v.AssignUint(u)
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		i, err := strconv.ParseInt(string(n), 10, bitSize(t.Kind()))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid %v: %v", n, t, err)
+		}
+		v.AssignInt(i)
+	case vdl.Float32, vdl.Float64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		f, err := strconv.ParseFloat(string(n), bitSize(t.Kind()))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid %v: %v", n, t, err)
+		}
+		v.AssignFloat(f)
+	case vdl.String:
+		s, ok := x.(string)
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		v.AssignString(s)
+	case vdl.Enum:
+		s, ok := x.(string)
+		if !ok || t.EnumIndex(s) < 0 {
+			return nil, jsonTypeError(x, t)
+		}
+		v.AssignEnumLabel(s)
+	case vdl.Array, vdl.List:
+		if t.IsBytes() {
+			s, ok := x.(string)
+			if !ok {
+				return nil, jsonTypeError(x, t)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 for %v: %v", t, err)
+			}
+			if t.Kind() == vdl.Array && len(b) != t.Len() {
+				return nil, fmt.Errorf("got %d bytes for %v", len(b), t)
+			}
+			v.AssignBytes(b)
+			return v, nil
+		}
+		a, ok := x.([]interface{})
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		if t.Kind() == vdl.Array {
+			if len(a) != t.Len() {
+				return nil, fmt.Errorf("got %d elements for %v", len(a), t)
+			}
+		} else {
+			v.AssignLen(len(a))
+		}
+		for i, ex := range a {
+			elem, err := jsonToValue(ex, t.Elem(), defaults)
+			if err != nil {
+				return nil, err
+			}
+			v.Index(i).Assign(elem)
+		}
+	case vdl.Map:
+		return jsonToMap(x, t, defaults)
+	case vdl.Struct:
+		o, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		for index, dv := range defaults.Fields(t) {
+			if dv != nil {
+				v.StructField(index).Assign(dv)
+			}
+		}
+		for name, fx := range o {
+			field, index := t.FieldByName(name)
+			if index < 0 {
+				return nil, fmt.Errorf("%v has no field %s", t, name)
+			}
+			fv, err := jsonToValue(fx, field.Type, defaults)
+			if err != nil {
+				return nil, err
+			}
+			v.StructField(index).Assign(fv)
+		}
+	case vdl.Union:
+		o, ok := x.(map[string]interface{})
+		if !ok || len(o) != 1 {
+			return nil, fmt.Errorf("a %v must be an object with a single field", t)
+		}
+		for name, fx := range o {
+			field, index := t.FieldByName(name)
+			if index < 0 {
+				return nil, fmt.Errorf("%v has no field %s", t, name)
+			}
+			fv, err := jsonToValue(fx, field.Type, defaults)
+			if err != nil {
+				return nil, err
+			}
+			v.AssignField(index, fv)
+		}
+	default:
+		return nil, fmt.Errorf("values of type %v cannot be represented in JSON", t)
+	}
+	return v, nil
+}
+
+func jsonToMap(x interface{}, t *vdl.Type, defaults Defaults) (*vdl.Value, error) {
+	v := vdl.ZeroValue(t)
+	if hasStringKeys(t) {
+		o, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, jsonTypeError(x, t)
+		}
+		for kx, ex := range o {
+			key, err := jsonToValue(kx, t.Key(), defaults)
+			if err != nil {
+				return nil, err
+			}
+			elem, err := jsonToValue(ex, t.Elem(), defaults)
+			if err != nil {
+				return nil, err
+			}
+			v.AssignMapIndex(key, elem)
+		}
+		return v, nil
+	}
+	a, ok := x.([]interface{})
+	if !ok {
+		return nil, jsonTypeError(x, t)
+	}
+	for _, entry := range a {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("the entries of a %v must be [key, value] arrays", t)
+		}
+		key, err := jsonToValue(pair[0], t.Key(), defaults)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := jsonToValue(pair[1], t.Elem(), defaults)
+		if err != nil {
+			return nil, err
+		}
+		v.AssignMapIndex(key, elem)
+	}
+	return v, nil
+}
+
+func jsonTypeError(x interface{}, t *vdl.Type) error {
+	b, _ := json.Marshal(x)
+	return fmt.Errorf("%s is not a valid %v", b, t)
+}
+
+func bitSize(k vdl.Kind) int {
+	switch k {
+	case vdl.Byte, vdl.Int8:
+		return 8
+	case vdl.Uint16, vdl.Int16:
+		return 16
+	case vdl.Uint32, vdl.Int32, vdl.Float32:
+		return 32
+	default:
+		return 64
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/x/mojo/transcoder"
+)
+
+var jsonShapeType = vdl.NamedType("test.Shape", vdl.StructType(
+	vdl.Field{"Name", vdl.StringType},
+	vdl.Field{"Kind", vdl.NamedType("test.Kind", vdl.EnumType("Circle", "Square"))},
+	vdl.Field{"Data", vdl.ListType(vdl.ByteType)},
+	vdl.Field{"Center", vdl.OptionalType(vdl.NamedType("test.Point", vdl.StructType(
+		vdl.Field{"X", vdl.Int32Type},
+		vdl.Field{"Y", vdl.Int32Type},
+	)))},
+	vdl.Field{"Value", vdl.NamedType("test.Value", vdl.UnionType(
+		vdl.Field{"I", vdl.Int64Type},
+		vdl.Field{"S", vdl.StringType},
+	))},
+	vdl.Field{"Labels", vdl.MapType(vdl.StringType, vdl.Int32Type)},
+	vdl.Field{"Ids", vdl.MapType(vdl.Int32Type, vdl.StringType)},
+	vdl.Field{"Scale", vdl.Float64Type},
+))
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{
+			`{"Name":"a","Kind":"Square","Data":"AQI=","Center":{"X":1,"Y":-2},"Value":{"S":"x"},"Labels":{"a":1,"b":2},"Ids":[[1,"one"],[2,"two"]],"Scale":0.5}`,
+			`{"Name":"a","Kind":"Square","Data":"AQI=","Center":{"X":1,"Y":-2},"Value":{"S":"x"},"Labels":{"a":1,"b":2},"Ids":[[1,"one"],[2,"two"]],"Scale":0.5}`,
+		},
+		{
+			// Missing fields are zero.
+			`{"Name": "b", "Value": {"I": 9007199254740993}}`,
+			`{"Name":"b","Kind":"Circle","Data":"","Center":null,"Value":{"I":9007199254740993},"Labels":{},"Ids":[],"Scale":0}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := transcoder.FromJSON([]byte(test.in), jsonShapeType, nil)
+		if err != nil {
+			t.Errorf("FromJSON(%s): %v", test.in, err)
+			continue
+		}
+		out, err := transcoder.ToJSON(data, jsonShapeType, nil)
+		if err != nil {
+			t.Errorf("ToJSON(%x): %v", data, err)
+			continue
+		}
+		if got := string(out); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"Nope": 1}`,
+		`{"Name": 1}`,
+		`{"Kind": "Triangle"}`,
+		`{"Center": {"X": 2147483648}}`,
+		`{"Value": {"I": 1, "S": "x"}}`,
+		`{"Ids": {"1": "one"}}`,
+		`{"Name": null}`,
+	} {
+		if _, err := transcoder.FromJSON([]byte(in), jsonShapeType, nil); err == nil {
+			t.Errorf("FromJSON(%s) succeeded", in)
+		}
+	}
+}
+
+func TestJSONFloat32(t *testing.T) {
+	out, err := transcoder.ValueToJSON(vdl.ValueOf(float32(0.1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "0.1"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The names of mojom and VDL types and their members follow different rules,
+// so they are converted as follows.
+//
+// Struct and union fields: mojom names in snake_case map to UpperCamelCase,
+// e.g., pod_union <-> PodUnion.
+//
+// Enum labels: mojom labels in UPPER_SNAKE_CASE map to UpperCamelCase, e.g.,
+// FIRST_VALUE <-> FirstValue.
+//
+// Other mojom names in UpperCamelCase, such as those of mojom files that are
+// written to match VDL, keep their name in VDL. Any other mojom name, e.g.,
+// camelCase, is escaped with the prefix X_, e.g., fooBar <-> X_fooBar.
+//
+// So every VDL name in UpperCamelCase or with the prefix X_ has a mojom name
+// that maps back to it, and every mojom name maps back to itself, except that
+// mojom names in UpperCamelCase come back in the style of their kind, e.g.,
+// the field PodUnion comes back as pod_union. Mojom names that map to the
+// same VDL name, e.g., the fields pod_union and PodUnion, cannot be used in
+// the same type.
+//
+// Vanadium converts values by these names, so they are part of the wire
+// format: before v23-0.2, fields and labels only had their first letter
+// capitalized, e.g., Pod_union and FIRST_VALUE, and proxies of those versions
+// cannot exchange such values with later ones. See the README.
+//
+// Types: a VDL package path maps to a mojom module by its elements, e.g.,
+// a/b/c.D <-> a.b.c.D. Elements that are not mojom identifiers, e.g., v.io,
+// are escaped with the prefix X_, and other characters than letters and
+// digits are escaped as _ and their hex code, so that v.io/x/y.Z <->
+// X_v_2eio.x.y.Z.
+
+// MojomToVDLFieldName returns the VDL name of a mojom struct or union field.
+func MojomToVDLFieldName(name string) (string, error) {
+	return mojomToVDLName(name, isLowerSnake)
+}
+
+// VDLToMojomFieldName returns the mojom name of a VDL struct or union field.
+func VDLToMojomFieldName(name string) (string, error) {
+	return vdlToMojomName(name, isLowerSnake, strings.ToLower)
+}
+
+// MojomToVDLEnumLabel returns the VDL label of a mojom enum value.
+func MojomToVDLEnumLabel(label string) (string, error) {
+	return mojomToVDLName(label, isUpperSnake)
+}
+
+// VDLToMojomEnumLabel returns the name of the mojom enum value for a VDL
+// enum label.
+func VDLToMojomEnumLabel(label string) (string, error) {
+	return vdlToMojomName(label, isUpperSnake, strings.ToUpper)
+}
+
+const escapePrefix = "X_"
+
+func mojomToVDLName(name string, canonical func(string) bool) (string, error) {
+	switch {
+	case !IsMojomIdentifier(name):
+		return "", fmt.Errorf("%q is not a mojom identifier", name)
+	case canonical(name):
+		return snakeToUpperCamel(name), nil
+	case isUpperCamel(name):
+		return name, nil
+	default:
+		return escapePrefix + name, nil
+	}
+}
+
+func vdlToMojomName(name string, canonical func(string) bool, toCase func(string) string) (string, error) {
+	switch {
+	case isUpperCamel(name):
+		return upperCamelToSnake(name, toCase), nil
+	case strings.HasPrefix(name, escapePrefix):
+		mojom := strings.TrimPrefix(name, escapePrefix)
+		if IsMojomIdentifier(mojom) && !canonical(mojom) && !isUpperCamel(mojom) {
+			return mojom, nil
+		}
+	}
+	return "", fmt.Errorf("VDL name %q has no mojom equivalent; use UpperCamelCase", name)
+}
+
+// isLowerSnake returns true for names like foo_bar2.
+func isLowerSnake(s string) bool {
+	return isSnake(s, func(r rune) bool { return 'a' <= r && r <= 'z' })
+}
+
+// isUpperSnake returns true for names like FOO_BAR2.
+func isUpperSnake(s string) bool {
+	return isSnake(s, func(r rune) bool { return 'A' <= r && r <= 'Z' })
+}
+
+// isSnake returns true if s is made of words that are separated by single
+// underscores, and that are made of letters for which isLetter returns true
+// and digits, starting with a letter.
+func isSnake(s string, isLetter func(rune) bool) bool {
+	for _, word := range strings.Split(s, "_") {
+		for i, r := range word {
+			if !isLetter(r) && (i == 0 || r < '0' || r > '9') {
+				return false
+			}
+		}
+		if word == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isUpperCamel returns true for names like FooBar2, which are made of words
+// that start with an upper case letter followed by lower case letters and
+// digits.
+func isUpperCamel(s string) bool {
+	for i, r := range s {
+		switch {
+		case 'A' <= r && r <= 'Z':
+		case i > 0 && ('a' <= r && r <= 'z' || '0' <= r && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// snakeToUpperCamel converts foo_bar or FOO_BAR to FooBar.
+func snakeToUpperCamel(s string) string {
+	words := strings.Split(s, "_")
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+	return strings.Join(words, "")
+}
+
+// upperCamelToSnake converts FooBar to foo_bar or FOO_BAR, depending on
+// toCase.
+func upperCamelToSnake(s string, toCase func(string) string) string {
+	var words []string
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	words = append(words, s[start:])
+	return toCase(strings.Join(words, "_"))
+}
+
+// IsMojomIdentifier returns true if s can name a mojom declaration: it is made
+// of letters, digits and underscores, and does not start with a digit.
+func IsMojomIdentifier(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// MojomToVDLTypeName returns the VDL name of the mojom type with the full
+// identifier ident, e.g., a/b/c.D for a.b.c.D.
+func MojomToVDLTypeName(ident string) (string, error) {
+	dot := strings.LastIndex(ident, ".")
+	module, name := "", ident
+	if dot != -1 {
+		module, name = ident[:dot], ident[dot+1:]
+	}
+	if !IsMojomIdentifier(name) {
+		return "", fmt.Errorf("%q is not a mojom type identifier", ident)
+	}
+	if module == "" {
+		return name, nil
+	}
+	pkgPath, err := MojomToVDLPackagePath(module)
+	if err != nil {
+		return "", err
+	}
+	return pkgPath + "." + name, nil
+}
+
+// VDLToMojomTypeName returns the full identifier of the mojom type for the
+// VDL type with the given name, e.g., a.b.c.D for a/b/c.D.
+func VDLToMojomTypeName(vdlName string) (string, error) {
+	dot := strings.LastIndex(vdlName, ".")
+	if slash := strings.LastIndex(vdlName, "/"); dot < slash {
+		dot = -1
+	}
+	pkgPath, name := "", vdlName
+	if dot != -1 {
+		pkgPath, name = vdlName[:dot], vdlName[dot+1:]
+	}
+	if !IsMojomIdentifier(name) {
+		return "", fmt.Errorf("VDL type name %q has no mojom equivalent", vdlName)
+	}
+	if pkgPath == "" {
+		return name, nil
+	}
+	module, err := VDLToMojomModule(pkgPath)
+	if err != nil {
+		return "", err
+	}
+	return module + "." + name, nil
+}
+
+// VDLToMojomModule returns the mojom module for a VDL package path.
+func VDLToMojomModule(pkgPath string) (string, error) {
+	elems := strings.Split(pkgPath, "/")
+	for i, elem := range elems {
+		if elem == "" {
+			return "", fmt.Errorf("VDL package path %q has an empty element", pkgPath)
+		}
+		if IsMojomIdentifier(elem) && !strings.HasPrefix(elem, escapePrefix) {
+			continue
+		}
+		escaped := escapePrefix
+		for _, b := range []byte(elem) {
+			if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' {
+				escaped += string(b)
+			} else {
+				escaped += fmt.Sprintf("_%02x", b)
+			}
+		}
+		elems[i] = escaped
+	}
+	return strings.Join(elems, "."), nil
+}
+
+// MojomToVDLPackagePath returns the VDL package path for a mojom module.
+func MojomToVDLPackagePath(module string) (string, error) {
+	parts := strings.Split(module, ".")
+	for i, part := range parts {
+		if !IsMojomIdentifier(part) {
+			return "", fmt.Errorf("%q is not a mojom module", module)
+		}
+		if !strings.HasPrefix(part, escapePrefix) {
+			continue
+		}
+		escaped := strings.TrimPrefix(part, escapePrefix)
+		var elem []byte
+		for j := 0; j < len(escaped); j++ {
+			if escaped[j] != '_' {
+				elem = append(elem, escaped[j])
+				continue
+			}
+			if j+3 > len(escaped) {
+				return "", fmt.Errorf("mojom module %q has a bad escape in %s", module, part)
+			}
+			b, err := strconv.ParseUint(escaped[j+1:j+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("mojom module %q has a bad escape in %s", module, part)
+			}
+			elem = append(elem, byte(b))
+			j += 2
+		}
+		// Only accept the escapes that VDLToMojomModule produces, so that
+		// the mapping stays one to one.
+		if again, err := VDLToMojomModule(string(elem)); err != nil || again != part {
+			return "", fmt.Errorf("mojom module %q has a bad escape in %s", module, part)
+		}
+		parts[i] = string(elem)
+	}
+	return strings.Join(parts, "/"), nil
+}
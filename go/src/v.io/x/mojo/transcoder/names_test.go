@@ -0,0 +1,101 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/x/mojo/transcoder"
+)
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name               string
+		toVDL, toMojom     func(string) (string, error)
+		mojom, vdl, mojom2 string // mojom2 is the mojom name that vdl maps back to
+	}{
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "pod_union", "PodUnion", "pod_union"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "f_int8", "FInt8", "f_int8"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "x", "X", "x"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "CyclicStruct", "CyclicStruct", "cyclic_struct"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "fooBar", "X_fooBar", "fooBar"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "FOO", "FOO", "f_o_o"},
+		{"field", transcoder.MojomToVDLFieldName, transcoder.VDLToMojomFieldName, "a__b", "X_a__b", "a__b"},
+		{"label", transcoder.MojomToVDLEnumLabel, transcoder.VDLToMojomEnumLabel, "FIRST_VALUE", "FirstValue", "FIRST_VALUE"},
+		{"label", transcoder.MojomToVDLEnumLabel, transcoder.VDLToMojomEnumLabel, "A", "A", "A"},
+		{"label", transcoder.MojomToVDLEnumLabel, transcoder.VDLToMojomEnumLabel, "TransUnion", "TransUnion", "TRANS_UNION"},
+		{"label", transcoder.MojomToVDLEnumLabel, transcoder.VDLToMojomEnumLabel, "first_value", "X_first_value", "first_value"},
+		{"type", transcoder.MojomToVDLTypeName, transcoder.VDLToMojomTypeName, "a.b.c.D", "a/b/c.D", "a.b.c.D"},
+		{"type", transcoder.MojomToVDLTypeName, transcoder.VDLToMojomTypeName, "D", "D", "D"},
+		{"type", transcoder.MojomToVDLTypeName, transcoder.VDLToMojomTypeName, "X_v_2eio.x.geo.Point", "v.io/x/geo.Point", "X_v_2eio.x.geo.Point"},
+		{"type", transcoder.MojomToVDLTypeName, transcoder.VDLToMojomTypeName, "X_X_5fa.Y", "X_a.Y", "X_X_5fa.Y"},
+		{"module", transcoder.MojomToVDLPackagePath, transcoder.VDLToMojomModule, "X_v_2eio.x.X_my_2dpkg", "v.io/x/my-pkg", "X_v_2eio.x.X_my_2dpkg"},
+	}
+	for _, test := range tests {
+		vdlName, err := test.toVDL(test.mojom)
+		if err != nil || vdlName != test.vdl {
+			t.Errorf("%s %s: got VDL name %q, %v, want %q", test.name, test.mojom, vdlName, err, test.vdl)
+			continue
+		}
+		mojomName, err := test.toMojom(vdlName)
+		if err != nil || mojomName != test.mojom2 {
+			t.Errorf("%s %s: got mojom name %q, %v, want %q", test.name, vdlName, mojomName, err, test.mojom2)
+			continue
+		}
+		// Every VDL name that has a mojom name comes back unchanged.
+		if again, err := test.toVDL(mojomName); err != nil || again != vdlName {
+			t.Errorf("%s %s: got VDL name %q, %v, want %q", test.name, mojomName, again, err, vdlName)
+		}
+	}
+}
+
+func TestNameErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		convert func(string) (string, error)
+		in      string
+	}{
+		{"field", transcoder.MojomToVDLFieldName, "1x"},
+		{"field", transcoder.MojomToVDLFieldName, ""},
+		{"field", transcoder.VDLToMojomFieldName, "fooBar"},
+		// pod_union maps to PodUnion, not X_pod_union.
+		{"field", transcoder.VDLToMojomFieldName, "X_pod_union"},
+		{"field", transcoder.VDLToMojomFieldName, "X_Foo"},
+		{"label", transcoder.VDLToMojomEnumLabel, "X_FOO"},
+		{"type", transcoder.MojomToVDLTypeName, "a.b-c.D"},
+		{"type", transcoder.VDLToMojomTypeName, "a//b.C"},
+		// Only the escapes that VDLToMojomModule produces are accepted.
+		{"module", transcoder.MojomToVDLPackagePath, "X_v_2"},
+		{"module", transcoder.MojomToVDLPackagePath, "X_abc"},
+		{"module", transcoder.MojomToVDLPackagePath, "X_a_2fb"},
+	}
+	for _, test := range tests {
+		if got, err := test.convert(test.in); err == nil {
+			t.Errorf("%s %q: got %q, want an error", test.name, test.in, got)
+		}
+	}
+}
+
+func TestNameCollision(t *testing.T) {
+	ident, first, second := "geo.Point", "pod_union", "PodUnion"
+	ms := mojom_types.MojomStruct{
+		DeclData: &mojom_types.DeclarationData{FullIdentifier: &ident},
+		Fields: []mojom_types.StructField{
+			{
+				DeclData: &mojom_types.DeclarationData{ShortName: &first},
+				Type:     &mojom_types.TypeSimpleType{mojom_types.SimpleType_Int32},
+			},
+			{
+				DeclData: &mojom_types.DeclarationData{ShortName: &second},
+				Type:     &mojom_types.TypeSimpleType{mojom_types.SimpleType_Int32},
+			},
+		},
+	}
+	if _, err := transcoder.MojomStructToVDLType(ms, nil); err == nil {
+		t.Errorf("converted a struct with the fields %s and %s", first, second)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"fmt"
+	"sort"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+)
+
+// VDLInterfaceToMojomInterface converts the signature of a Vanadium interface
+// to a mojom interface and the user defined types that it refers to. Method
+// ordinals follow the order of iface.Methods, and every method gets response
+// params, since Vanadium methods always reply. Access tags and
+// MethodAttributes become method attributes; other tags are dropped.
+func VDLInterfaceToMojomInterface(iface signature.Interface) (mi mojom_types.MojomInterface, mp map[string]mojom_types.UserDefinedType, err error) {
+	defer func() {
+		// The conversion of types panics on the VDL types that mojom cannot
+		// represent.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot convert %s to mojom: %v", iface.Name, r)
+		}
+	}()
+	ident, err := VDLToMojomTypeName(iface.PkgPath + "." + iface.Name)
+	if err != nil {
+		return mojom_types.MojomInterface{}, nil, err
+	}
+	mp = map[string]mojom_types.UserDefinedType{}
+	mi = mojom_types.MojomInterface{
+		DeclData: &mojom_types.DeclarationData{
+			ShortName:      strPtr(iface.Name),
+			FullIdentifier: strPtr(ident),
+		},
+		Methods: map[uint32]mojom_types.MojomMethod{},
+	}
+	for i, m := range iface.Methods {
+		if m.InStream != nil || m.OutStream != nil {
+			return mojom_types.MojomInterface{}, nil, fmt.Errorf("cannot convert streaming method %s.%s to mojom", iface.Name, m.Name)
+		}
+		response := argsToMojomStruct(m.OutArgs, mp)
+		mi.Methods[uint32(i)] = mojom_types.MojomMethod{
+			DeclData:       &mojom_types.DeclarationData{ShortName: strPtr(m.Name), Attributes: mojomAttributes(m.Tags)},
+			Parameters:     argsToMojomStruct(m.InArgs, mp),
+			ResponseParams: &response,
+			Ordinal:        uint32(i),
+		}
+	}
+	return mi, mp, nil
+}
+
+// argsToMojomStruct returns the unnamed struct that holds args.
+func argsToMojomStruct(args []signature.Arg, mp map[string]mojom_types.UserDefinedType) mojom_types.MojomStruct {
+	fields := make([]mojom_types.StructField, len(args))
+	for i, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		fields[i] = mojom_types.StructField{
+			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(name)},
+			Type:     vdlToMojomTypeInternal(arg.Type, false, false, mp),
+		}
+	}
+	return mojom_types.MojomStruct{
+		DeclData: &mojom_types.DeclarationData{},
+		Fields:   fields,
+	}
+}
+
+// MojomInterfaceToVDLInterface converts a mojom interface to the signature of
+// the Vanadium interface that the v23 proxies serve for it: each param is an
+// argument of the type that the proxies send for it, and the attributes of
+// methods are their tags, see MojomMethodTags. Methods are sorted by name, as
+// in the signatures of other Vanadium services.
+func MojomInterfaceToVDLInterface(mi mojom_types.MojomInterface, mp map[string]mojom_types.UserDefinedType) (iface signature.Interface, err error) {
+	defer func() {
+		// The conversion of types panics on the mojom types that VDL cannot
+		// represent.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot convert %s to VDL: %v", *mi.DeclData.ShortName, r)
+		}
+	}()
+	iface.Name = *mi.DeclData.ShortName
+	if mi.DeclData.FullIdentifier != nil {
+		name, err := MojomToVDLTypeName(*mi.DeclData.FullIdentifier)
+		if err != nil {
+			return signature.Interface{}, err
+		}
+		iface.PkgPath, _ = vdl.SplitIdent(name)
+	}
+	for _, mm := range mi.Methods {
+		m := signature.Method{Name: *mm.DeclData.ShortName}
+		if m.Tags, err = MojomMethodTags(mm.DeclData); err != nil {
+			return signature.Interface{}, fmt.Errorf("%s.%s: %v", iface.Name, m.Name, err)
+		}
+		if m.InArgs, err = mojomStructToArgs(mm.Parameters, mp); err != nil {
+			return signature.Interface{}, err
+		}
+		if mm.ResponseParams != nil {
+			if m.OutArgs, err = mojomStructToArgs(*mm.ResponseParams, mp); err != nil {
+				return signature.Interface{}, err
+			}
+		}
+		iface.Methods = append(iface.Methods, m)
+	}
+	SortMethods(iface.Methods)
+	return iface, nil
+}
+
+// mojomStructToArgs returns an argument for each field of params.
+func mojomStructToArgs(params mojom_types.MojomStruct, mp map[string]mojom_types.UserDefinedType) ([]signature.Arg, error) {
+	t, err := MojomStructToVDLType(params, mp)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]signature.Arg, len(params.Fields))
+	for i, field := range params.Fields {
+		args[i] = signature.Arg{Name: *field.DeclData.ShortName, Type: t.Field(i).Type}
+	}
+	return args, nil
+}
+
+// SortMethods sorts methods by name, the order of the methods in the
+// signatures of Vanadium services.
+func SortMethods(methods []signature.Method) {
+	sort.Sort(methodsByName(methods))
+}
+
+type methodsByName []signature.Method
+
+func (m methodsByName) Len() int           { return len(m) }
+func (m methodsByName) Less(i, j int) bool { return m[i].Name < m[j].Name }
+func (m methodsByName) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
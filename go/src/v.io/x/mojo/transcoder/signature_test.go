@@ -0,0 +1,113 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/transcoder"
+)
+
+func TestVDLInterfaceToMojomInterface(t *testing.T) {
+	pointType := vdl.NamedType("v.io/x/geo.Point", vdl.StructType(vdl.Field{"X", vdl.Int32Type}, vdl.Field{"Y", vdl.Int32Type}))
+	iface := signature.Interface{
+		Name:    "Geo",
+		PkgPath: "v.io/x/geo",
+		Methods: []signature.Method{
+			{
+				Name:    "Distance",
+				InArgs:  []signature.Arg{{Name: "a", Type: pointType}, {Name: "b", Type: pointType}},
+				OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float64Type}},
+			},
+			{
+				Name: "Reset",
+			},
+		},
+	}
+	mi, mp, err := transcoder.VDLInterfaceToMojomInterface(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *mi.DeclData.ShortName, "Geo"; got != want {
+		t.Errorf("got interface name %q, want %q", got, want)
+	}
+	if got, want := len(mi.Methods), 2; got != want {
+		t.Fatalf("got %d methods, want %d", got, want)
+	}
+	for ordinal, name := range []string{"Distance", "Reset"} {
+		m, ok := mi.Methods[uint32(ordinal)]
+		if !ok || *m.DeclData.ShortName != name {
+			t.Errorf("method %d is not %s", ordinal, name)
+			continue
+		}
+		if m.ResponseParams == nil {
+			t.Errorf("%s has no response params", name)
+		}
+	}
+
+	// The converted params must transcode to the VDL types of the arguments.
+	distance := mi.Methods[0]
+	inType, err := transcoder.MojomStructToVDLType(distance.Parameters, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inType.NumField(), 2; got != want {
+		t.Fatalf("got %d params, want %d", got, want)
+	}
+	if got, want := inType.Field(0).Type.Kind(), vdl.Struct; got != want {
+		t.Errorf("got kind %v, want %v", got, want)
+	}
+	outType, err := transcoder.MojomStructToVDLType(*distance.ResponseParams, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outType.Field(0).Type, vdl.Float64Type; got != want {
+		t.Errorf("got result type %v, want %v", got, want)
+	}
+
+	unsupported := signature.Interface{
+		Name: "Any",
+		Methods: []signature.Method{
+			{Name: "Get", OutArgs: []signature.Arg{{Name: "v", Type: vdl.AnyType}}},
+		},
+	}
+	if _, _, err := transcoder.VDLInterfaceToMojomInterface(unsupported); err == nil {
+		t.Errorf("converted a method returning any")
+	}
+}
+
+func TestMojomInterfaceToVDLInterface(t *testing.T) {
+	pointType := vdl.NamedType("geo.Point", vdl.StructType(vdl.Field{"X", vdl.Int32Type}, vdl.Field{"Y", vdl.Int32Type}))
+	want := signature.Interface{
+		Name:    "Geo",
+		PkgPath: "geo",
+		Methods: []signature.Method{
+			{
+				Name:    "Distance",
+				InArgs:  []signature.Arg{{Name: "a", Type: pointType}, {Name: "b", Type: pointType}},
+				OutArgs: []signature.Arg{{Name: "d", Type: vdl.Float64Type}},
+			},
+			{
+				Name:    "Reset",
+				InArgs:  []signature.Arg{},
+				OutArgs: []signature.Arg{},
+			},
+		},
+	}
+	mi, mp, err := transcoder.VDLInterfaceToMojomInterface(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := transcoder.MojomInterfaceToVDLInterface(mi, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
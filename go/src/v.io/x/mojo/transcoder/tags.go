@@ -0,0 +1,144 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder
+
+import (
+	"fmt"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/security/access"
+	"v.io/v23/vdl"
+)
+
+// The attributes of mojom methods are the tags of the VDL methods, e.g.,
+//
+//	interface Store {
+//	  [Access="Read"] Get(string key) => (string value);
+//	  [Idempotent] Put(string key, string value) => ();
+//	};
+//
+// gives Get the tag access.Read, which Vanadium authorizers such as
+// access.PermissionsAuthorizer evaluate, and Put the tag
+// MethodAttribute{"Idempotent", true}, which is declared in tags.vdl.
+
+// AccessAttribute is the mojom method attribute whose value is the Vanadium
+// access tag of the method, e.g., [Access="Read"].
+const AccessAttribute = "Access"
+
+// IdempotentAttribute marks a mojom method that may safely run more than
+// once, e.g.,
+//
+//	[Idempotent] Get(string key) => (string value);
+//
+// Its tag is MethodAttribute{"Idempotent", true}, see Idempotent.
+const IdempotentAttribute = "Idempotent"
+
+// MojomMethodTags returns the VDL method tags for the attributes in decl, the
+// declaration of a mojom method, in order. It returns nil if decl has no
+// attributes.
+func MojomMethodTags(decl *mojom_types.DeclarationData) ([]*vdl.Value, error) {
+	if decl == nil || decl.Attributes == nil {
+		return nil, nil
+	}
+	var tags []*vdl.Value
+	for _, attr := range *decl.Attributes {
+		var x interface{} = true
+		if attr.Value != nil {
+			var err error
+			if x, err = literalToGo(attr.Value); err != nil {
+				return nil, fmt.Errorf("attribute %s: %v", attr.Key, err)
+			}
+		}
+		if attr.Key != AccessAttribute {
+			tags = append(tags, vdl.ValueOf(MethodAttribute{attr.Key, vdl.ValueOf(x)}))
+			continue
+		}
+		tag, err := accessTag(x)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, vdl.ValueOf(tag))
+	}
+	return tags, nil
+}
+
+// Idempotent returns true if tags, the VDL method tags of a method, include
+// IdempotentAttribute. An explicit [Idempotent=false] is honored.
+func Idempotent(tags []*vdl.Value) bool {
+	for _, tag := range tags {
+		if tag.Type() != vdl.TypeOf(MethodAttribute{}) || tag.StructField(0).RawString() != IdempotentAttribute {
+			continue
+		}
+		value := tag.StructField(1)
+		if value.Kind() == vdl.Any {
+			value = value.Elem()
+		}
+		return value == nil || value.Kind() != vdl.Bool || value.Bool()
+	}
+	return false
+}
+
+// accessTag returns the typical access tag named x.
+func accessTag(x interface{}) (access.Tag, error) {
+	if name, ok := x.(string); ok {
+		for _, tag := range access.AllTypicalTags() {
+			if string(tag) == name {
+				return tag, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("attribute %s: %v is not one of the access tags %v", AccessAttribute, x, access.AllTypicalTags())
+}
+
+// mojomAttributes returns the mojom method attributes for the VDL method tags,
+// or nil if there are none. The tags other than access tags and
+// MethodAttributes have no mojom form and are dropped.
+func mojomAttributes(tags []*vdl.Value) *[]mojom_types.Attribute {
+	var attrs []mojom_types.Attribute
+	for _, tag := range tags {
+		switch {
+		case tag.Type() == access.TypicalTagType():
+			attrs = append(attrs, mojom_types.Attribute{
+				Key:   AccessAttribute,
+				Value: &mojom_types.LiteralValueStringValue{tag.RawString()},
+			})
+		case tag.Type() == vdl.TypeOf(MethodAttribute{}):
+			key, value := tag.StructField(0).RawString(), tag.StructField(1)
+			if value.Kind() == vdl.Any {
+				value = value.Elem()
+			}
+			literal := goToLiteral(value)
+			if literal == nil {
+				continue
+			}
+			attrs = append(attrs, mojom_types.Attribute{Key: key, Value: literal})
+		}
+	}
+	if attrs == nil {
+		return nil
+	}
+	return &attrs
+}
+
+// goToLiteral returns the mojom literal for v, or nil if v is not a scalar.
+func goToLiteral(v *vdl.Value) mojom_types.LiteralValue {
+	if v == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case vdl.Bool:
+		return &mojom_types.LiteralValueBoolValue{v.Bool()}
+	case vdl.String:
+		return &mojom_types.LiteralValueStringValue{v.RawString()}
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		return &mojom_types.LiteralValueUint64Value{v.Uint()}
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		return &mojom_types.LiteralValueInt64Value{v.Int()}
+	case vdl.Float32, vdl.Float64:
+		return &mojom_types.LiteralValueDoubleValue{v.Float()}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file was auto-generated by the vanadium vdl tool.
+// Package: transcoder
+
+package transcoder
+
+import (
+	"fmt"
+	"reflect"
+	"v.io/v23/vdl"
+)
+
+var _ = __VDLInit() // Must be first; see __VDLInit comments for details.
+
+//////////////////////////////////////////////////
+// Type definitions
+
+// MethodAttribute is the VDL method tag for a mojom method attribute other
+// than Access. Attributes without a value, e.g., [Idempotent], have the value
+// true.
+type MethodAttribute struct {
+	Key   string
+	Value *vdl.Value
+}
+
+func (MethodAttribute) __VDLReflect(struct {
+	Name string `vdl:"src/v.io/x/mojo/transcoder.MethodAttribute"`
+}) {
+}
+
+func (m *MethodAttribute) FillVDLTarget(t vdl.Target, tt *vdl.Type) error {
+	fieldsTarget1, err := t.StartFields(tt)
+	if err != nil {
+		return err
+	}
+	var4 := (m.Key == "")
+	if var4 {
+		if err := fieldsTarget1.ZeroField("Key"); err != nil && err != vdl.ErrFieldNoExist {
+			return err
+		}
+	} else {
+		keyTarget2, fieldTarget3, err := fieldsTarget1.StartField("Key")
+		if err != vdl.ErrFieldNoExist {
+			if err != nil {
+				return err
+			}
+			if err := fieldTarget3.FromString(string(m.Key), tt.NonOptional().Field(0).Type); err != nil {
+				return err
+			}
+			if err := fieldsTarget1.FinishField(keyTarget2, fieldTarget3); err != nil {
+				return err
+			}
+		}
+	}
+	var7 := m.Value == nil || (m.Value.Kind() == vdl.Any && m.Value.IsZero())
+	if var7 {
+		if err := fieldsTarget1.ZeroField("Value"); err != nil && err != vdl.ErrFieldNoExist {
+			return err
+		}
+	} else {
+		keyTarget5, fieldTarget6, err := fieldsTarget1.StartField("Value")
+		if err != vdl.ErrFieldNoExist {
+			if err != nil {
+				return err
+			}
+			if err := vdl.FromValue(fieldTarget6, m.Value); err != nil {
+				return err
+			}
+			if err := fieldsTarget1.FinishField(keyTarget5, fieldTarget6); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.FinishFields(fieldsTarget1); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *MethodAttribute) MakeVDLTarget() vdl.Target {
+	return &MethodAttributeTarget{Value: m}
+}
+
+type MethodAttributeTarget struct {
+	Value     *MethodAttribute
+	keyTarget vdl.StringTarget
+	vdl.TargetBase
+	vdl.FieldsTargetBase
+}
+
+func (t *MethodAttributeTarget) StartFields(tt *vdl.Type) (vdl.FieldsTarget, error) {
+
+	if ttWant := vdl.TypeOf((*MethodAttribute)(nil)).Elem(); !vdl.Compatible(tt, ttWant) {
+		return nil, fmt.Errorf("type %v incompatible with %v", tt, ttWant)
+	}
+	return t, nil
+}
+func (t *MethodAttributeTarget) StartField(name string) (key, field vdl.Target, _ error) {
+	switch name {
+	case "Key":
+		t.keyTarget.Value = &t.Value.Key
+		target, err := &t.keyTarget, error(nil)
+		return nil, target, err
+	case "Value":
+		target, err := vdl.ReflectTarget(reflect.ValueOf(&t.Value.Value))
+		return nil, target, err
+	default:
+		return nil, nil, vdl.ErrFieldNoExist
+	}
+}
+func (t *MethodAttributeTarget) FinishField(_, _ vdl.Target) error {
+	return nil
+}
+func (t *MethodAttributeTarget) ZeroField(name string) error {
+	switch name {
+	case "Key":
+		t.Value.Key = ""
+		return nil
+	case "Value":
+		t.Value.Value = vdl.ZeroValue(vdl.AnyType)
+		return nil
+	default:
+		return vdl.ErrFieldNoExist
+	}
+}
+func (t *MethodAttributeTarget) FinishFields(_ vdl.FieldsTarget) error {
+
+	return nil
+}
+
+func (x MethodAttribute) VDLIsZero() bool {
+	if x.Key != "" {
+		return false
+	}
+	if x.Value != nil && !x.Value.IsZero() {
+		return false
+	}
+	return true
+}
+
+func (x MethodAttribute) VDLWrite(enc vdl.Encoder) error {
+	if err := enc.StartValue(vdl.TypeOf((*MethodAttribute)(nil)).Elem()); err != nil {
+		return err
+	}
+	if x.Key != "" {
+		if err := enc.NextField("Key"); err != nil {
+			return err
+		}
+		if err := enc.StartValue(vdl.StringType); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(x.Key); err != nil {
+			return err
+		}
+		if err := enc.FinishValue(); err != nil {
+			return err
+		}
+	}
+	if x.Value != nil && !x.Value.IsZero() {
+		if err := enc.NextField("Value"); err != nil {
+			return err
+		}
+		if err := x.Value.VDLWrite(enc); err != nil {
+			return err
+		}
+	}
+	if err := enc.NextField(""); err != nil {
+		return err
+	}
+	return enc.FinishValue()
+}
+
+func (x *MethodAttribute) VDLRead(dec vdl.Decoder) error {
+	*x = MethodAttribute{
+		Value: vdl.ZeroValue(vdl.AnyType),
+	}
+	if err := dec.StartValue(); err != nil {
+		return err
+	}
+	if (dec.StackDepth() == 1 || dec.IsAny()) && !vdl.Compatible(vdl.TypeOf(*x), dec.Type()) {
+		return fmt.Errorf("incompatible struct %T, from %v", *x, dec.Type())
+	}
+	for {
+		f, err := dec.NextField()
+		if err != nil {
+			return err
+		}
+		switch f {
+		case "":
+			return dec.FinishValue()
+		case "Key":
+			if err := dec.StartValue(); err != nil {
+				return err
+			}
+			var err error
+			if x.Key, err = dec.DecodeString(); err != nil {
+				return err
+			}
+			if err := dec.FinishValue(); err != nil {
+				return err
+			}
+		case "Value":
+			x.Value = new(vdl.Value)
+			if err := x.Value.VDLRead(dec); err != nil {
+				return err
+			}
+		default:
+			if err := dec.SkipValue(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var __VDLInitCalled bool
+
+// __VDLInit performs vdl initialization.  It is safe to call multiple times.
+// If you have an init ordering issue, just insert the following line verbatim
+// into your source files in this package, right after the "package foo" clause:
+//
+//    var _ = __VDLInit()
+//
+// The purpose of this function is to ensure that vdl initialization occurs in
+// the right order, and very early in the init sequence.  In particular, vdl
+// registration and package variable initialization needs to occur before
+// functions like vdl.TypeOf will work properly.
+//
+// This function returns a dummy value, so that it can be used to initialize the
+// first var in the file, to take advantage of Go's defined init order.
+func __VDLInit() struct{} {
+	if __VDLInitCalled {
+		return struct{}{}
+	}
+	__VDLInitCalled = true
+
+	// Register types.
+	vdl.Register((*MethodAttribute)(nil))
+
+	return struct{}{}
+}
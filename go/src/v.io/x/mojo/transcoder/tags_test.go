@@ -0,0 +1,106 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transcoder_test
+
+import (
+	"testing"
+
+	"mojo/public/interfaces/bindings/mojom_types"
+
+	"v.io/v23/security/access"
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+	"v.io/x/mojo/transcoder"
+)
+
+func TestMojomMethodTags(t *testing.T) {
+	attrs := []mojom_types.Attribute{
+		{Key: "Access", Value: &mojom_types.LiteralValueStringValue{"Read"}},
+		{Key: "Idempotent"},
+		{Key: "Timeout", Value: &mojom_types.LiteralValueInt8Value{5}},
+	}
+	tags, err := transcoder.MojomMethodTags(&mojom_types.DeclarationData{Attributes: &attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*vdl.Value{
+		vdl.ValueOf(access.Read),
+		vdl.ValueOf(transcoder.MethodAttribute{"Idempotent", vdl.BoolValue(true)}),
+		vdl.ValueOf(transcoder.MethodAttribute{"Timeout", vdl.ValueOf(int8(5))}),
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("got tags %v, want %v", tags, want)
+	}
+	for i := range tags {
+		if !vdl.EqualValue(tags[i], want[i]) {
+			t.Errorf("got tag %v, want %v", tags[i], want[i])
+		}
+	}
+
+	if tags, err := transcoder.MojomMethodTags(&mojom_types.DeclarationData{}); err != nil || tags != nil {
+		t.Errorf("got tags %v, %v for a method without attributes", tags, err)
+	}
+	bad := []mojom_types.Attribute{{Key: "Access", Value: &mojom_types.LiteralValueStringValue{"Reader"}}}
+	if _, err := transcoder.MojomMethodTags(&mojom_types.DeclarationData{Attributes: &bad}); err == nil {
+		t.Errorf("accepted the access tag Reader")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	tests := []struct {
+		attrs []mojom_types.Attribute
+		want  bool
+	}{
+		{nil, false},
+		{[]mojom_types.Attribute{{Key: "Access", Value: &mojom_types.LiteralValueStringValue{"Read"}}}, false},
+		{[]mojom_types.Attribute{{Key: transcoder.IdempotentAttribute}}, true},
+		{[]mojom_types.Attribute{{Key: transcoder.IdempotentAttribute, Value: &mojom_types.LiteralValueBoolValue{false}}}, false},
+	}
+	for _, test := range tests {
+		tags, err := transcoder.MojomMethodTags(&mojom_types.DeclarationData{Attributes: &test.attrs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := transcoder.Idempotent(tags); got != test.want {
+			t.Errorf("Idempotent(%v) = %v, want %v", tags, got, test.want)
+		}
+	}
+}
+
+func TestMethodTagsRoundTrip(t *testing.T) {
+	iface := signature.Interface{
+		Name:    "Store",
+		PkgPath: "store",
+		Methods: []signature.Method{
+			{Name: "Get", Tags: []*vdl.Value{vdl.ValueOf(access.Read)}},
+			{Name: "Put", Tags: []*vdl.Value{vdl.ValueOf(access.Write), vdl.ValueOf(transcoder.MethodAttribute{"Idempotent", vdl.BoolValue(true)})}},
+			// Tags without a mojom form are dropped.
+			{Name: "Reset", Tags: []*vdl.Value{vdl.Int32Value(1)}},
+		},
+	}
+	mi, mp, err := transcoder.VDLInterfaceToMojomInterface(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := transcoder.MojomInterfaceToVDLInterface(mi, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range got.Methods {
+		want := iface.Methods[i].Tags
+		if m.Name == "Reset" {
+			want = nil
+		}
+		if len(m.Tags) != len(want) {
+			t.Errorf("%s: got tags %v, want %v", m.Name, m.Tags, want)
+			continue
+		}
+		for j := range want {
+			if !vdl.EqualValue(m.Tags[j], want[j]) {
+				t.Errorf("%s: got tag %v, want %v", m.Name, m.Tags[j], want[j])
+			}
+		}
+	}
+}
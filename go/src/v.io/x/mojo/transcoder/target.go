@@ -319,11 +319,15 @@ func structFieldShared(tt *vdl.Type, allocator *allocator, writePointer bool) (v
 			vdlType: tt,
 			block:   block,
 			layout:  computeStructLayout(tt),
+			started: make([]bool, tt.NumField()),
 		},
 		block, nil
 }
 
 func (t target) FinishFields(x vdl.FieldsTarget) error {
+	if fe, ok := x.(fieldsTarget); ok {
+		return fe.finish()
+	}
 	return nil
 }
 
@@ -10,20 +10,22 @@ import (
 	"v.io/v23/vdl"
 )
 
-// ToMojom encodes a value as mojom.
+// ToMojom encodes a value as mojom, giving the struct fields that the value
+// does not set their value in defaults.
 // This differs from the standard mojo encode because it uses the
 // vdl package for reflection and can therefore handle RawBytes
 // and vdl.Value.
-func ToMojom(value interface{}) ([]byte, error) {
-	vtm := ToMojomTarget()
+func ToMojom(value interface{}, defaults Defaults) ([]byte, error) {
+	vtm := ToMojomTarget(defaults)
 	err := vdl.FromReflect(vtm, reflect.ValueOf(value))
 	return vtm.Bytes(), err
 }
 
-// ToMojomTarget creates a vdl.Target that writes mojom bytes.
-func ToMojomTarget() *targetToMojomTranscoder {
+// ToMojomTarget creates a vdl.Target that writes mojom bytes, giving the
+// struct fields that are not set their value in defaults.
+func ToMojomTarget(defaults Defaults) *targetToMojomTranscoder {
 	return &targetToMojomTranscoder{
-		allocator: &allocator{},
+		allocator: &allocator{defaults: defaults},
 	}
 }
 
@@ -92,7 +94,7 @@ func (vtm *targetToMojomTranscoder) StartFields(tt *vdl.Type) (vdl.FieldsTarget,
 }
 
 func (vtm *targetToMojomTranscoder) FinishFields(x vdl.FieldsTarget) error {
-	return nil
+	return x.(fieldsTarget).finish()
 }
 
 func (vtm *targetToMojomTranscoder) FromNil(tt *vdl.Type) error {
@@ -6,25 +6,21 @@ package transcoder
 
 import (
 	"fmt"
-	"strings"
-	"unicode"
-	"unicode/utf8"
 
 	"mojo/public/interfaces/bindings/mojom_types"
 
 	"v.io/v23/vdl"
 )
 
-// upperCamelCase converts thisString to ThisString.
-func upperCamelCase(s string) string {
-	if s == "" {
-		return ""
-	}
-	r, n := utf8.DecodeRuneInString(s)
-	return string(unicode.ToUpper(r)) + s[n:]
-}
-
-func MojomStructToVDLType(ms mojom_types.MojomStruct, mp map[string]mojom_types.UserDefinedType) (*vdl.Type, error) {
+// MojomStructToVDLType returns the VDL type of values of the mojom struct ms,
+// whose user defined types are described by mp.
+func MojomStructToVDLType(ms mojom_types.MojomStruct, mp map[string]mojom_types.UserDefinedType) (t *vdl.Type, err error) {
+	defer func() {
+		// The conversion panics on the mojom types that VDL cannot represent.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
 	builder := &vdl.TypeBuilder{}
 	// Note: The type key is "" below because if there is a cycle, it will have a separate reference under a separate
 	// type key and if there isn't the key is irrelevant.
@@ -33,7 +29,14 @@ func MojomStructToVDLType(ms mojom_types.MojomStruct, mp map[string]mojom_types.
 	return pending.Built()
 }
 
-func MojomToVDLType(mt mojom_types.Type, mp map[string]mojom_types.UserDefinedType) (*vdl.Type, error) {
+// MojomToVDLType returns the VDL type of values of the mojom type mt, whose
+// user defined types are described by mp.
+func MojomToVDLType(mt mojom_types.Type, mp map[string]mojom_types.UserDefinedType) (vt *vdl.Type, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
 	builder := &vdl.TypeBuilder{}
 	t := mojomToVDLType(mt, mp, builder, map[string]vdl.TypeOrPending{})
 	builder.Build()
@@ -44,16 +47,65 @@ func MojomToVDLType(mt mojom_types.Type, mp map[string]mojom_types.UserDefinedTy
 
 }
 
+// vdlTypeName returns the VDL name of the mojom type with the given
+// declaration data.
+func vdlTypeName(decl *mojom_types.DeclarationData) string {
+	name, err := MojomToVDLTypeName(*decl.FullIdentifier)
+	if err != nil {
+		panic(err)
+	}
+	return name
+}
+
+// vdlMemberNames converts the mojom names of the fields or labels of the type
+// ident with convert, and panics if two of them have the same VDL name.
+func vdlMemberNames(ident string, mojomNames []string, convert func(string) (string, error)) []string {
+	names := make([]string, len(mojomNames))
+	seen := map[string]string{}
+	for i, mojomName := range mojomNames {
+		name, err := convert(mojomName)
+		if err != nil {
+			panic(fmt.Errorf("%s: %v", ident, err))
+		}
+		if other, ok := seen[name]; ok {
+			panic(fmt.Errorf("%s: %s and %s both map to the VDL name %s", ident, other, mojomName, name))
+		}
+		seen[name] = mojomName
+		names[i] = name
+	}
+	return names
+}
+
+// mojomMemberNames is the inverse of vdlMemberNames.
+func mojomMemberNames(t *vdl.Type, vdlNames []string, convert func(string) (string, error)) []string {
+	names := make([]string, len(vdlNames))
+	for i, vdlName := range vdlNames {
+		name, err := convert(vdlName)
+		if err != nil {
+			panic(fmt.Errorf("%s: %v", t.Name(), err))
+		}
+		names[i] = name
+	}
+	return names
+}
+
 func mojomStructToVDLType(typeKey string, ms mojom_types.MojomStruct, mp map[string]mojom_types.UserDefinedType, builder *vdl.TypeBuilder, pendingUdts map[string]vdl.TypeOrPending) (vt vdl.PendingType) {
 	strct := builder.Struct()
+	ident := ""
 	if ms.DeclData.FullIdentifier != nil {
-		vt = builder.Named(mojomToVdlPath(*ms.DeclData.FullIdentifier)).AssignBase(strct)
+		ident = *ms.DeclData.FullIdentifier
+		vt = builder.Named(vdlTypeName(ms.DeclData)).AssignBase(strct)
 	} else {
 		vt = strct
 	}
 	pendingUdts[typeKey] = vt
-	for _, mfield := range ms.Fields {
-		strct.AppendField(upperCamelCase(*mfield.DeclData.ShortName), mojomToVDLType(mfield.Type, mp, builder, pendingUdts))
+	mojomNames := make([]string, len(ms.Fields))
+	for i, mfield := range ms.Fields {
+		mojomNames[i] = *mfield.DeclData.ShortName
+	}
+	names := vdlMemberNames(ident, mojomNames, MojomToVDLFieldName)
+	for i, mfield := range ms.Fields {
+		strct.AppendField(names[i], mojomToVDLType(mfield.Type, mp, builder, pendingUdts))
 	}
 	return
 }
@@ -65,14 +117,15 @@ func mojomToVDLTypeUDT(typeKey string, udt mojom_types.UserDefinedType, mp map[s
 		me := u.Value
 
 		// TODO: Assumes that the maximum enum index is len(me.Values) - 1.
-		labels := make([]string, len(me.Values))
+		mojomLabels := make([]string, len(me.Values))
 		for _, ev := range me.Values { // per EnumValue...
 			// EnumValue has DeclData, EnumTypeKey, and IntValue.
 			// We just need the first and last.
-			labels[int(ev.IntValue)] = upperCamelCase(*ev.DeclData.ShortName)
+			mojomLabels[int(ev.IntValue)] = *ev.DeclData.ShortName
 		}
+		labels := vdlMemberNames(*me.DeclData.FullIdentifier, mojomLabels, MojomToVDLEnumLabel)
 
-		vt = vdl.NamedType(mojomToVdlPath(*me.DeclData.FullIdentifier), vdl.EnumType(labels...))
+		vt = vdl.NamedType(vdlTypeName(me.DeclData), vdl.EnumType(labels...))
 		pendingUdts[typeKey] = vt
 	case *mojom_types.UserDefinedTypeStructType: // struct
 		vt = mojomStructToVDLType(typeKey, u.Value, mp, builder, pendingUdts)
@@ -80,10 +133,15 @@ func mojomToVDLTypeUDT(typeKey string, udt mojom_types.UserDefinedType, mp map[s
 		mu := u.Value
 
 		union := builder.Union()
-		vt = builder.Named(mojomToVdlPath(*mu.DeclData.FullIdentifier)).AssignBase(union)
+		vt = builder.Named(vdlTypeName(mu.DeclData)).AssignBase(union)
 		pendingUdts[typeKey] = vt
-		for _, mfield := range mu.Fields {
-			union = union.AppendField(upperCamelCase(*mfield.DeclData.ShortName), mojomToVDLType(mfield.Type, mp, builder, pendingUdts))
+		mojomNames := make([]string, len(mu.Fields))
+		for i, mfield := range mu.Fields {
+			mojomNames[i] = *mfield.DeclData.ShortName
+		}
+		names := vdlMemberNames(*mu.DeclData.FullIdentifier, mojomNames, MojomToVDLFieldName)
+		for i, mfield := range mu.Fields {
+			union = union.AppendField(names[i], mojomToVDLType(mfield.Type, mp, builder, pendingUdts))
 		}
 	case *mojom_types.UserDefinedTypeInterfaceType: // interface
 		panic("interfaces don't exist in vdl")
@@ -176,12 +234,28 @@ func mojomToVDLType(mojomtype mojom_types.Type, mp map[string]mojom_types.UserDe
 	return vt
 }
 
+// VDLToMojomType returns the mojom type of values of the VDL type t and the
+// user defined types that it refers to. It panics if t cannot be represented
+// in mojom, e.g., if it has a field whose name has no mojom form; use
+// VDLToMojomTypeE to get an error instead.
 func VDLToMojomType(t *vdl.Type) (mojomtype mojom_types.Type, mp map[string]mojom_types.UserDefinedType) {
 	mp = map[string]mojom_types.UserDefinedType{}
 	mojomtype = vdlToMojomTypeInternal(t, true, false, mp)
 	return
 }
 
+// VDLToMojomTypeE is like VDLToMojomType, but returns an error if t cannot be
+// represented in mojom.
+func VDLToMojomTypeE(t *vdl.Type) (mojomtype mojom_types.Type, mp map[string]mojom_types.UserDefinedType, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			mojomtype, mp, err = nil, nil, fmt.Errorf("%v", r)
+		}
+	}()
+	mojomtype, mp = VDLToMojomType(t)
+	return mojomtype, mp, nil
+}
+
 func vdlToMojomTypeInternal(t *vdl.Type, outermostType bool, nullable bool, mp map[string]mojom_types.UserDefinedType) (mojomtype mojom_types.Type) {
 	switch t.Kind() {
 	case vdl.Bool, vdl.Float64, vdl.Float32, vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64, vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
@@ -297,11 +371,21 @@ func mapType(key, value mojom_types.Type, nullable bool) mojom_types.MapType {
 	return mojom_types.MapType{nullable, key, value}
 }
 
+// fieldNames returns the mojom names of the fields of the struct or union t.
+func fieldNames(t *vdl.Type) []string {
+	vdlNames := make([]string, t.NumField())
+	for i := range vdlNames {
+		vdlNames[i] = t.Field(i).Name
+	}
+	return mojomMemberNames(t, vdlNames, VDLToMojomFieldName)
+}
+
 func structType(t *vdl.Type, mp map[string]mojom_types.UserDefinedType) mojom_types.UserDefinedType {
+	names := fieldNames(t)
 	structFields := make([]mojom_types.StructField, t.NumField())
 	for i := 0; i < t.NumField(); i++ {
 		structFields[i] = mojom_types.StructField{
-			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(t.Field(i).Name)},
+			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(names[i])},
 			Type:     vdlToMojomTypeInternal(t.Field(i).Type, false, false, mp),
 			Offset:   0, // Despite the fact that we can calculated the offset, set it to zero to match the generator
 		}
@@ -319,10 +403,11 @@ func structType(t *vdl.Type, mp map[string]mojom_types.UserDefinedType) mojom_ty
 }
 
 func unionType(t *vdl.Type, mp map[string]mojom_types.UserDefinedType) mojom_types.UserDefinedType {
+	names := fieldNames(t)
 	unionFields := make([]mojom_types.UnionField, t.NumField())
 	for i := 0; i < t.NumField(); i++ {
 		unionFields[i] = mojom_types.UnionField{
-			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(t.Field(i).Name)},
+			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(names[i])},
 			Type:     vdlToMojomTypeInternal(t.Field(i).Type, false, false, mp),
 			Tag:      uint32(i),
 		}
@@ -340,10 +425,15 @@ func unionType(t *vdl.Type, mp map[string]mojom_types.UserDefinedType) mojom_typ
 }
 
 func enumType(t *vdl.Type) mojom_types.UserDefinedType {
+	vdlLabels := make([]string, t.NumEnumLabel())
+	for i := range vdlLabels {
+		vdlLabels[i] = t.EnumLabel(i)
+	}
+	labels := mojomMemberNames(t, vdlLabels, VDLToMojomEnumLabel)
 	enumValues := make([]mojom_types.EnumValue, t.NumEnumLabel())
 	for i := 0; i < t.NumEnumLabel(); i++ {
 		enumValues[i] = mojom_types.EnumValue{
-			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(t.EnumLabel(i))},
+			DeclData: &mojom_types.DeclarationData{ShortName: strPtr(labels[i])},
 			IntValue: int32(i),
 		}
 	}
@@ -369,15 +459,12 @@ func mojomTypeKey(t *vdl.Type) string {
 	return fmt.Sprintf("TYPE_KEY:%s", mojomIdentifier(t))
 }
 
+// mojomIdentifier returns the full identifier of the mojom type for the named
+// VDL type t.
 func mojomIdentifier(t *vdl.Type) string {
-	return strings.Replace(t.Name(), "/", ".", -1)
-}
-
-// "a.b.c.D" -> "a/b/c.D"
-func mojomToVdlPath(path string) string {
-	lastDot := strings.LastIndex(path, ".")
-	if lastDot == -1 {
-		return path
+	ident, err := VDLToMojomTypeName(t.Name())
+	if err != nil {
+		panic(err)
 	}
-	return strings.Replace(path[:lastDot], ".", "/", -1) + path[lastDot:]
+	return ident
 }
@@ -169,3 +169,15 @@ func TestVdlAndMojoTypeConversion(t *testing.T) {
 		}
 	}
 }
+
+func TestVDLToMojomTypeE(t *testing.T) {
+	// Foo_bar has no mojom field name that maps back to it.
+	bad := vdl.NamedType("geo.Bad", vdl.StructType(vdl.Field{"Foo_bar", vdl.Int32Type}))
+	if _, _, err := transcoder.VDLToMojomTypeE(bad); err == nil {
+		t.Errorf("converted %v to mojom", bad)
+	}
+	good := vdl.NamedType("geo.Good", vdl.StructType(vdl.Field{"FooBar", vdl.Int32Type}))
+	if _, mp, err := transcoder.VDLToMojomTypeE(good); err != nil || len(mp) != 1 {
+		t.Errorf("got %v, %v, want one user defined type", mp, err)
+	}
+}
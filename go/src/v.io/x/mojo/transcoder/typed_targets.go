@@ -15,11 +15,15 @@ type fieldsTarget struct {
 	vdlType *vdl.Type
 	block   bytesRef
 	layout  structLayout
+	// started holds the fields that have been started, so that the others
+	// can be set to their default value when the struct is finished.
+	started []bool
 }
 
 func (fe fieldsTarget) StartField(name string) (key, field vdl.Target, _ error) {
 	fieldType, fieldIndex := fe.vdlType.FieldByName(name)
 	byteOffset, bitOffset := fe.layout.MojoOffsetsFromVdlIndex(fieldIndex)
+	fe.started[fieldIndex] = true
 
 	numBits := baseTypeSizeBits(fieldType.Type)
 	refSize := (numBits + 7) / 8
@@ -47,6 +51,27 @@ func (fe fieldsTarget) ZeroField(name string) error {
 	return fe.FinishField(key, field)
 }
 
+// finish sets the fields that the source of the struct had no value for,
+// e.g., because it is an older version of the struct, to their default value.
+func (fe fieldsTarget) finish() error {
+	for i, started := range fe.started {
+		if started {
+			continue
+		}
+		key, field, err := fe.StartField(fe.vdlType.Field(i).Name)
+		if err != nil {
+			return err
+		}
+		if err := vdl.FromValue(field, fe.block.allocator.defaults.field(fe.vdlType, i)); err != nil {
+			return err
+		}
+		if err := fe.FinishField(key, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type unionFieldsTarget struct {
 	vdlType *vdl.Type
 	block   bytesRef